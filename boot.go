@@ -0,0 +1,73 @@
+package main
+
+import (
+	"github.com/StellarisJAY/gbgo/cartridge"
+	"github.com/StellarisJAY/gbgo/cpu"
+	"github.com/StellarisJAY/gbgo/model"
+)
+
+// postBootRegisters 各型号启动ROM结束时的cpu寄存器值
+// cgbMode为false表示CGB/AGB以DMG兼容模式运行老游戏
+func postBootRegisters(m model.Model, h cartridge.Header, cgbMode bool) cpu.PostBoot {
+	var r cpu.PostBoot
+	switch {
+	case m == model.DMG0:
+		r.A, r.F = 0x01, 0
+		r.B, r.C = 0xFF, 0x13
+		r.D, r.E = 0x00, 0xC1
+		r.H, r.L = 0x84, 0x03
+	case m == model.DMG || m == model.MGB:
+		r.A, r.F = 0x01, cpu.FlagZ
+		if m == model.MGB {
+			r.A = 0xFF
+		}
+		// 启动ROM最后计算头部校验和，结果不为0时设置H和C
+		if h.HeaderChecksum != 0 {
+			r.F |= cpu.FlagH | cpu.FlagC
+		}
+		r.B, r.C = 0x00, 0x13
+		r.D, r.E = 0x00, 0xD8
+		r.H, r.L = 0x01, 0x4D
+	case m == model.SGB || m == model.SGB2:
+		r.A, r.F = 0x01, 0
+		if m == model.SGB2 {
+			r.A = 0xFF
+		}
+		r.B, r.C = 0x00, 0x14
+		r.D, r.E = 0x00, 0x00
+		r.H, r.L = 0xC0, 0x60
+	case cgbMode:
+		r.A, r.F = 0x11, cpu.FlagZ
+		r.B, r.C = 0x00, 0x00
+		r.D, r.E = 0xFF, 0x56
+		r.H, r.L = 0x00, 0x0D
+		if m == model.AGB {
+			// AGB的启动ROM最后多执行了一次INC B
+			r.F, r.B = 0, 0x01
+		}
+	default:
+		// DMG兼容模式，任天堂发行的游戏由标题校验和选择调色板，B保存标题校验和
+		r.A, r.F = 0x11, cpu.FlagZ
+		if h.LicenseeCode == "01" {
+			r.B = h.TitleChecksum
+		}
+		r.C = 0x00
+		r.D, r.E = 0x00, 0x08
+		if r.B == 0x43 || r.B == 0x58 {
+			r.H, r.L = 0x99, 0x1A
+		} else {
+			r.H, r.L = 0x00, 0x7C
+		}
+		if m == model.AGB {
+			r.B++
+			r.F = 0
+			if r.B == 0 {
+				r.F |= cpu.FlagZ
+			}
+			if r.B&0xF == 0 {
+				r.F |= cpu.FlagH
+			}
+		}
+	}
+	return r
+}
@@ -0,0 +1,58 @@
+package bus
+
+import (
+	"fmt"
+
+	"github.com/StellarisJAY/gbgo/model"
+)
+
+const (
+	dmgBootROMSize = 0x100 // DMG启动ROM，映射到0x0000~0x00FF
+	cgbBootROMSize = 0x900 // CGB启动ROM，映射到0x0000~0x00FF和0x0200~0x08FF，中间是卡带头部
+)
+
+// LoadBootROM 加载启动ROM，在写入0xFF50之前覆盖卡带的低地址
+// 启动ROM需要在LCD关闭时初始化显存，所以这里会关闭LCD，必须在ConnectPPU之后调用
+func (b *Bus) LoadBootROM(data []byte) error {
+	if len(data) != dmgBootROMSize && len(data) != cgbBootROMSize {
+		return fmt.Errorf("boot rom size must be %d or %d bytes, got %d", dmgBootROMSize, cgbBootROMSize, len(data))
+	}
+	b.bootROM = data
+	b.bootROMMapped = true
+	b.ppu.WriteRegister(0xFF40, 0)
+	return nil
+}
+
+// inBootROM 地址是否被启动ROM覆盖
+func (b *Bus) inBootROM(addr uint16) bool {
+	if !b.bootROMMapped {
+		return false
+	}
+	return addr < dmgBootROMSize || (len(b.bootROM) == cgbBootROMSize && addr >= 0x0200 && addr < cgbBootROMSize)
+}
+
+// 没有启动ROM时DIV内部计数器的初始值，DMG的高8位来自文档，其余是常见模拟器使用的近似值
+var divCounters = [...]uint16{
+	model.DMG0: 0x1830,
+	model.DMG:  0xABCC,
+	model.MGB:  0xABCC,
+	model.CGB:  0x1EA0,
+	model.AGB:  0x1EA0,
+	model.SGB:  0xABCC,
+	model.SGB2: 0xABCC,
+}
+
+// SkipBoot 不运行启动ROM，把IO寄存器设置为启动ROM结束时的值
+func (b *Bus) SkipBoot(m model.Model) {
+	b.bootROMMapped = false
+	b.timer.SetCounter(divCounters[m])
+	if m == model.SGB || m == model.SGB2 {
+		b.joypad.Write(0x30) // SGB启动ROM结束时没有选择按键组，P1=0xFF
+	} else {
+		b.joypad.Write(0x00)
+	}
+	b.iFlag.Write(0x01) // IF=0xE1，启动ROM结束时VBlank中断已经请求
+	b.ppu.WriteRegister(0xFF47, 0xFC)
+	b.ppu.WriteRegister(0xFF48, 0xFF)
+	b.ppu.WriteRegister(0xFF49, 0xFF)
+}
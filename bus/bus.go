@@ -3,45 +3,97 @@ package bus
 import (
 	"github.com/StellarisJAY/gbgo/cartridge"
 	"github.com/StellarisJAY/gbgo/interrupt"
+	"github.com/StellarisJAY/gbgo/joypad"
 	"github.com/StellarisJAY/gbgo/ppu"
+	"github.com/StellarisJAY/gbgo/timer"
 )
 
 // Bus 虚拟总线，cpu通过总线地址访问内存和硬件
 type Bus struct {
-	workRAMBanks [][]byte // 可切换的bank 0~7
+	workRAMBanks [][]byte // bank 0固定在0xC000，0xD000是可切换的bank 1~7
 	wRAMSelect   byte
+	cgbMode      bool // 以CGB模式运行，WRAM和显存bank切换、KEY1等CGB功能只在这个模式下可用
 	highRAM      []byte
 	cartridge    *cartridge.BasicCartridge // 卡带数据
 	ppu          *ppu.PPU                  // ppu 显卡
+	timer        *timer.Timer              // 定时器
+	joypad       *joypad.Joypad            // 手柄
 
 	iEnable *interrupt.Register // IE 寄存器
 	iFlag   *interrupt.Register // IF 寄存器
+
+	bootROM       []byte // 启动ROM，没有时为nil
+	bootROMMapped bool   // 启动ROM是否还覆盖卡带，写入0xFF50后解除
+
+	doubleSpeed      bool // CGB 双倍速模式
+	speedSwitchArmed bool // KEY1 bit0，下一次STOP时切换速度
+
+	cycleAccurate bool // OAM DMA按M-cycle逐字节进行，而不是写入0xFF46时立即完成
+	dma           dma
 }
 
 func MakeBus(cart *cartridge.BasicCartridge) *Bus {
 	b := &Bus{
-		highRAM:   make([]byte, 256),
-		cartridge: cart,
-		iEnable:   interrupt.NewRegister(),
-		iFlag:     interrupt.NewRegister(),
+		highRAM:    make([]byte, 256),
+		cartridge:  cart,
+		iEnable:    interrupt.NewRegister(),
+		iFlag:      interrupt.NewRegister(),
+		wRAMSelect: 1,
 	}
+	// DMG只使用bank 0和1，CGB模式下可以切换到2~7
 	b.workRAMBanks = make([][]byte, 8)
-	b.workRAMBanks[0] = make([]byte, 0x1000)
-	// CGB模式下的1~7号bank
-	if b.cartridge.IsCGBMode() {
-		for i := 1; i <= 7; i++ {
-			b.workRAMBanks[i] = make([]byte, 0x1000)
-		}
+	for i := range b.workRAMBanks {
+		b.workRAMBanks[i] = make([]byte, 0x1000)
 	}
 	return b
 }
 
+// SetCGBMode 设置是否以CGB模式运行，由硬件型号和卡带共同决定，必须与计算cpu.PostBoot时使用的cgbMode一致
+func (b *Bus) SetCGBMode(on bool) {
+	b.cgbMode = on
+}
+
 func (b *Bus) ConnectPPU(ppu *ppu.PPU) {
 	b.ppu = ppu
 }
 
-func (b *Bus) ReadMem8(addr uint16) byte {
+func (b *Bus) ConnectTimer(t *timer.Timer) {
+	b.timer = t
+}
+
+func (b *Bus) ConnectJoypad(j *joypad.Joypad) {
+	b.joypad = j
+}
+
+// SetCycleAccurate 开启后OAM DMA需要160个M-cycle完成，期间cpu访问DMA占用的总线会产生冲突
+func (b *Bus) SetCycleAccurate(on bool) {
+	b.cycleAccurate = on
+}
+
+// Tick cpu执行指令后推进其他设备cycles个周期
+func (b *Bus) Tick(cycles uint64) {
+	b.tickDMA(cycles)
+	b.timer.Tick(cycles)
+	// 双倍速模式下PPU和卡带时钟的频率不变
+	if b.doubleSpeed {
+		cycles /= 2
+	}
+	b.ppu.Tick(cycles)
+	b.cartridge.Tick(cycles)
+}
+
+func (b *Bus) Read(addr uint16) byte {
+	if b.dmaConflict(addr) {
+		return b.dma.conflictValue(addr)
+	}
+	return b.read(addr)
+}
+
+// read 读取地址，不检查OAM DMA的总线冲突
+func (b *Bus) read(addr uint16) byte {
 	switch {
+	case b.inBootROM(addr): // 启动ROM
+		return b.bootROM[addr]
 	case addr >= 0x0000 && addr <= 0x7FFF: // cartridge banks
 		return b.cartridge.Read(addr)
 	case addr >= 0x8000 && addr <= 0x9FFF: // 显存，可切换bank 0/1
@@ -52,15 +104,35 @@ func (b *Bus) ReadMem8(addr uint16) byte {
 		return b.workRAMBanks[0][addr-0xC000]
 	case addr >= 0xD000 && addr <= 0xDFFF: // work ram bank 1~7
 		return b.workRAMBanks[b.wRAMSelect][addr-0xD000]
+	case addr >= 0xFE00 && addr <= 0xFE9F: // OAM
+		return b.ppu.ReadOAM(addr)
 	case addr >= 0xFF80 && addr <= 0xFFFE:
 		return b.highRAM[addr-0xFF80]
-	case addr == 0xFF44: // LY
-		return b.ppu.ReadScanline()
+	case addr >= 0xFF40 && addr <= 0xFF4B && addr != 0xFF46: // LCD寄存器
+		return b.ppu.ReadRegister(addr)
+	case addr == 0xFF00: // P1/JOYP
+		return b.joypad.Read()
+	case addr >= 0xFF04 && addr <= 0xFF07: // DIV, TIMA, TMA, TAC
+		return b.timer.Read(addr)
+	case addr == 0xFF0F: // IF，高3位始终为1
+		return b.iFlag.Read() | 0xE0
+	case addr == 0xFFFF: // IE
+		return b.iEnable.Read()
+	case addr == 0xFF4D: // KEY1
+		return b.readKey1()
+	case addr == 0xFF46: // DMA
+		return b.dma.register
+	case addr == 0xFF50: // BANK，只写
+		return 0xFF
 	}
 	return 0
 }
 
-func (b *Bus) WriteMem8(addr uint16, data byte) {
+func (b *Bus) Write(addr uint16, data byte) {
+	// OAM DMA占用的总线上的写入被忽略
+	if b.dmaConflict(addr) {
+		return
+	}
 	switch {
 	case addr >= 0x0000 && addr <= 0x7FFF: // cartridge banks
 		b.cartridge.Write(addr, data)
@@ -72,20 +144,41 @@ func (b *Bus) WriteMem8(addr uint16, data byte) {
 		b.workRAMBanks[0][addr-0xC000] = data
 	case addr >= 0xD000 && addr <= 0xDFFF: // work ram bank 1~7
 		b.workRAMBanks[b.wRAMSelect][addr-0xD000] = data
+	case addr >= 0xFE00 && addr <= 0xFE9F: // OAM
+		b.ppu.WriteOAM(addr, data)
 	case addr >= 0xFF80 && addr <= 0xFFFE:
 		b.highRAM[addr-0xFF80] = data
 	case addr == 0xFF46: // OAM DMA
-		dmaAddr := uint16(data) << 8
-		b.dmaWriteOAM(dmaAddr)
+		b.startDMA(data)
+	case addr >= 0xFF40 && addr <= 0xFF4B && addr != 0xFF46: // LCD寄存器
+		b.ppu.WriteRegister(addr, data)
 	case addr == 0xFF4F: // switch vRAM bank0/1
-		b.ppu.SwitchVRAMBank(data & 1)
+		if b.cgbMode {
+			b.ppu.SwitchVRAMBank(data & 1)
+		}
 	case addr == 0xFF70: // switch work RAM bank 1~7
 		b.switchWorkRAM(data & 7)
+	case addr == 0xFF00: // P1/JOYP
+		b.joypad.Write(data)
+	case addr >= 0xFF04 && addr <= 0xFF07: // DIV, TIMA, TMA, TAC
+		b.timer.Write(addr, data)
+	case addr == 0xFF0F: // IF
+		b.iFlag.Write(data & 0x1F)
+	case addr == 0xFFFF: // IE
+		b.iEnable.Write(data)
+	case addr == 0xFF4D: // KEY1
+		if b.cgbMode {
+			b.speedSwitchArmed = data&1 != 0
+		}
+	case addr == 0xFF50: // BANK，写入1后解除启动ROM，之后无法再映射
+		if data&1 != 0 {
+			b.bootROMMapped = false
+		}
 	}
 }
 
 func (b *Bus) switchWorkRAM(bankSel byte) {
-	if b.cartridge.IsCGBMode() {
+	if b.cgbMode {
 		if bankSel == 0 {
 			b.wRAMSelect = 1
 		} else {
@@ -94,24 +187,53 @@ func (b *Bus) switchWorkRAM(bankSel byte) {
 	}
 }
 
-func (b *Bus) ReadMem16(addr uint16) uint16 {
-	low, high := b.ReadMem8(addr), b.ReadMem8(addr+1)
-	return uint16(high)<<8 + uint16(low)
+func (b *Bus) readKey1() byte {
+	if !b.cgbMode {
+		return 0xFF
+	}
+	var data byte = 0x7E
+	if b.doubleSpeed {
+		data |= 0x80
+	}
+	if b.speedSwitchArmed {
+		data |= 1
+	}
+	return data
 }
 
-// OAM DMA 从addr地址拷贝OAM数据到PPU的OAM内存
-func (b *Bus) dmaWriteOAM(addr uint16) {
-	start, end := addr, addr+0x9F
-	buffer := make([]byte, 160) // OAM内存大小是160字节
-	for i := start; i <= end; i++ {
-		buffer[i-start] = b.ReadMem8(i)
+// SwitchSpeed 在STOP指令执行时调用，如果KEY1已经准备好切换，则切换CGB的速度模式
+func (b *Bus) SwitchSpeed() bool {
+	if !b.speedSwitchArmed {
+		return false
 	}
-	b.ppu.WriteOAM(buffer)
+	b.speedSwitchArmed = false
+	b.doubleSpeed = !b.doubleSpeed
+	return true
+}
+
+// DoubleSpeed cpu是否处于CGB双倍速模式
+func (b *Bus) DoubleSpeed() bool {
+	return b.doubleSpeed
 }
 
 // RequestInterrupt 设备通过该方法向总线发起中断
 func (b *Bus) RequestInterrupt(code interrupt.Code) {
-	b.iFlag.Get(code)
+	b.iFlag.Set(code, true)
+}
+
+// PendingInterrupt 返回IE和IF中同时置位的最高优先级中断
+func (b *Bus) PendingInterrupt() (interrupt.Code, bool) {
+	return interrupt.Pending(b.iEnable, b.iFlag)
+}
+
+// InterruptRequested IF中是否有某个中断请求，不考虑IE
+func (b *Bus) InterruptRequested(code interrupt.Code) bool {
+	return b.iFlag.Get(code)
+}
+
+// AcknowledgeInterrupt cpu开始处理中断时清除IF中对应的请求位
+func (b *Bus) AcknowledgeInterrupt(code interrupt.Code) {
+	b.iFlag.Set(code, false)
 }
 
 func (b *Bus) disableAllInterrupts() {
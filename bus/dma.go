@@ -0,0 +1,100 @@
+package bus
+
+// OAM DMA传输的字节数，与OAM大小相同
+const dmaLength = 160
+
+// 新的传输接管总线之前的M-cycle数：写入0xFF46所在的M-cycle，以及之后总线仍然空闲的一个M-cycle
+const dmaStartDelay = 2
+
+// dma 定时的OAM DMA状态
+type dma struct {
+	register byte   // 0xFF46 最后写入的值
+	active   bool   // 是否正在传输，传输期间占用OAM和源地址所在的总线
+	source   uint16 // 源地址
+	index    int    // 下一个传输的字节序号
+	last     byte   // 最后一次传输的字节，总线冲突时cpu读到这个值
+	cycles   uint64 // 不足一个M-cycle的剩余周期
+
+	delay int    // 新的传输开始之前剩余的M-cycle数
+	next  uint16 // 等待开始的传输的源地址
+}
+
+// startDMA 写入0xFF46开始OAM DMA；传输中再次写入会从头开始新的传输，
+// 新的传输开始之前旧的传输继续进行，总线一直被占用
+func (b *Bus) startDMA(data byte) {
+	b.dma.register = data
+	source := uint16(data) << 8
+	// 0xE000之后的源地址映射到工作RAM
+	if source >= 0xE000 {
+		source -= 0x2000
+	}
+	if !b.cycleAccurate {
+		buffer := make([]byte, dmaLength)
+		for i := range buffer {
+			buffer[i] = b.dmaRead(source + uint16(i))
+		}
+		b.ppu.CopyOAM(buffer)
+		return
+	}
+	if !b.dma.active {
+		b.dma.cycles = 0
+	}
+	b.dma.delay = dmaStartDelay
+	b.dma.next = source
+}
+
+// tickDMA 每个M-cycle传输一个字节
+func (b *Bus) tickDMA(cycles uint64) {
+	if !b.dma.active && b.dma.delay == 0 {
+		return
+	}
+	b.dma.cycles += cycles
+	for (b.dma.active || b.dma.delay > 0) && b.dma.cycles >= 4 {
+		b.dma.cycles -= 4
+		if b.dma.active {
+			b.dma.last = b.dmaRead(b.dma.source + uint16(b.dma.index))
+			b.ppu.DMAWriteOAM(b.dma.index, b.dma.last)
+			b.dma.index++
+			b.dma.active = b.dma.index < dmaLength
+		}
+		if b.dma.delay > 0 {
+			if b.dma.delay--; b.dma.delay == 0 {
+				b.dma.active = true
+				b.dma.source = b.dma.next
+				b.dma.index = 0
+			}
+		}
+	}
+}
+
+// dmaRead DMA读取源数据，不经过cpu的访问路径，不受PPU模式限制
+func (b *Bus) dmaRead(addr uint16) byte {
+	if isVRAM(addr) {
+		return b.ppu.DMAReadVRAM(addr)
+	}
+	return b.read(addr)
+}
+
+// dmaConflict DMA传输时cpu只能访问HRAM和IO寄存器；OAM总是被占用，
+// 显存和外部总线(卡带、工作RAM)中与DMA源地址相同的那一条被占用
+func (b *Bus) dmaConflict(addr uint16) bool {
+	if !b.dma.active || addr >= 0xFF00 {
+		return false
+	}
+	if addr >= 0xFE00 {
+		return true
+	}
+	return isVRAM(addr) == isVRAM(b.dma.source)
+}
+
+// conflictValue 总线冲突时cpu读到的值：OAM返回0xFF，其他地址读到DMA正在传输的字节
+func (d *dma) conflictValue(addr uint16) byte {
+	if addr >= 0xFE00 {
+		return 0xFF
+	}
+	return d.last
+}
+
+func isVRAM(addr uint16) bool {
+	return addr >= 0x8000 && addr <= 0x9FFF
+}
@@ -0,0 +1,211 @@
+package bus
+
+import (
+	"testing"
+
+	"github.com/StellarisJAY/gbgo/cartridge"
+	"github.com/StellarisJAY/gbgo/cpu"
+	"github.com/StellarisJAY/gbgo/joypad"
+	"github.com/StellarisJAY/gbgo/ppu"
+	"github.com/StellarisJAY/gbgo/timer"
+)
+
+var nintendoLogo = []byte{
+	0xCE, 0xED, 0x66, 0x66, 0xCC, 0x0D, 0x00, 0x0B, 0x03, 0x73, 0x00, 0x83, 0x00, 0x0C, 0x00, 0x0D,
+	0x00, 0x08, 0x11, 0x1F, 0x88, 0x89, 0x00, 0x0E, 0xDC, 0xCC, 0x6E, 0xE6, 0xDD, 0xDD, 0xD9, 0x99,
+	0xBB, 0xBB, 0x67, 0x63, 0x6E, 0x0E, 0xEC, 0xCC, 0xDD, 0xDC, 0x99, 0x9F, 0xBB, 0xB9, 0x33, 0x3E,
+}
+
+// makeTestBus 32KiB没有MBC的卡带，program放在0x0000；LCD关闭，OAM和显存总是可以访问
+func makeTestBus(t *testing.T, accurate bool, program ...byte) *Bus {
+	rom := make([]byte, 0x8000)
+	copy(rom, program)
+	copy(rom[0x104:], nintendoLogo)
+	var sum byte
+	for _, x := range rom[0x134:0x14D] {
+		sum = sum - x - 1
+	}
+	rom[0x14D] = sum
+	// 测试ROM不计算全局校验和，全局校验和错误不影响加载
+	c, err := cartridge.MakeBasicCartridge(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := MakeBus(c)
+	b.ConnectPPU(ppu.MakePPU(b.RequestInterrupt))
+	b.ConnectTimer(timer.MakeTimer(b.RequestInterrupt))
+	b.ConnectJoypad(joypad.MakeJoypad(b.RequestInterrupt))
+	b.SetCycleAccurate(accurate)
+	b.Write(0xFF40, 0)
+	return b
+}
+
+// fill 写入length字节，值为seed, seed+1...
+func fill(b *Bus, addr uint16, length int, seed byte) {
+	for i := 0; i < length; i++ {
+		b.Write(addr+uint16(i), seed+byte(i))
+	}
+}
+
+// checkOAM OAM应该与从seed开始的递增数据一致
+func checkOAM(t *testing.T, b *Bus, seed byte) {
+	t.Helper()
+	for i := 0; i < dmaLength; i++ {
+		if actual, expected := b.Read(0xFE00+uint16(i)), seed+byte(i); actual != expected {
+			t.Fatalf("oam[%d]: expected %02X, got %02X", i, expected, actual)
+		}
+	}
+}
+
+// startDMA 写入0xFF46并推进写入所在的M-cycle
+func startDMA(b *Bus, page byte) {
+	b.Write(0xFF46, page)
+	b.Tick(4)
+}
+
+func TestDMAInstant(t *testing.T) {
+	b := makeTestBus(t, false)
+	fill(b, 0xC100, dmaLength, 1)
+	b.Write(0xFF46, 0xC1)
+	checkOAM(t, b, 1)
+	if b.Read(0xFF46) != 0xC1 {
+		t.Errorf("expected 0xFF46 to read back C1, got %02X", b.Read(0xFF46))
+	}
+}
+
+func TestDMATiming(t *testing.T) {
+	b := makeTestBus(t, true)
+	fill(b, 0xC100, dmaLength, 1)
+	startDMA(b, 0xC1)
+	// 写入之后的一个M-cycle总线仍然空闲，OAM还没有被写入
+	if actual := b.Read(0xFE00); actual != 0 {
+		t.Fatalf("oam should be accessible during the start delay, got %02X", actual)
+	}
+	b.Tick(4)
+	busy := 0
+	for i := 0; i < 2*dmaLength && b.Read(0xFE00) == 0xFF; i++ {
+		b.Tick(4)
+		busy++
+	}
+	if busy != dmaLength {
+		t.Fatalf("expected the transfer to occupy the bus for %d M-cycles, got %d", dmaLength, busy)
+	}
+	checkOAM(t, b, 1)
+}
+
+func TestDMAConflicts(t *testing.T) {
+	b := makeTestBus(t, true)
+	fill(b, 0xC100, dmaLength, 1)
+	b.Write(0x9000, 0x42)
+	b.Write(0xFF90, 0x24)
+	startDMA(b, 0xC1)
+	for i := 0; i < 11; i++ {
+		b.Tick(4)
+	}
+	// 已经传输了10个字节，最后一个是0x0A
+	for _, addr := range []uint16{0x0150, 0xA000, 0xC000, 0xD000} {
+		if actual := b.Read(addr); actual != 0x0A {
+			t.Errorf("read %04X on the source bus: expected last dma byte 0A, got %02X", addr, actual)
+		}
+	}
+	if actual := b.Read(0xFE00); actual != 0xFF {
+		t.Errorf("read oam during dma: expected FF, got %02X", actual)
+	}
+	// 显存在另一条总线上，HRAM和IO寄存器不受影响
+	if actual := b.Read(0x9000); actual != 0x42 {
+		t.Errorf("read vram during wram dma: expected 42, got %02X", actual)
+	}
+	if actual := b.Read(0xFF90); actual != 0x24 {
+		t.Errorf("read hram during dma: expected 24, got %02X", actual)
+	}
+	b.Write(0xFF91, 0x99)
+	b.Write(0xC000, 0x99)
+	for i := 0; i < dmaLength; i++ {
+		b.Tick(4)
+	}
+	if actual := b.Read(0xFF91); actual != 0x99 {
+		t.Errorf("hram write during dma was lost, got %02X", actual)
+	}
+	if actual := b.Read(0xC000); actual == 0x99 {
+		t.Errorf("wram write on the dma source bus should be ignored")
+	}
+}
+
+func TestDMARestart(t *testing.T) {
+	b := makeTestBus(t, true)
+	fill(b, 0xC100, dmaLength, 1)
+	fill(b, 0xC200, dmaLength, 0x80)
+	startDMA(b, 0xC1)
+	for i := 0; i < 20; i++ {
+		b.Tick(4)
+	}
+	// 重新开始时旧的传输继续占用总线，直到新的传输接管
+	startDMA(b, 0xC2)
+	if actual := b.Read(0xFE00); actual != 0xFF {
+		t.Fatalf("oam should stay blocked while dma restarts, got %02X", actual)
+	}
+	for i := 0; i <= dmaLength; i++ {
+		b.Tick(4)
+	}
+	checkOAM(t, b, 0x80)
+}
+
+func TestDMAFromVRAMDuringMode3(t *testing.T) {
+	b := makeTestBus(t, true)
+	fill(b, 0x8000, dmaLength, 0x20)
+	b.Write(0xFF40, 0x91)
+	for i := 0; i < 1000 && b.Read(0xFF41)&3 != 3; i++ {
+		b.Tick(4)
+	}
+	if b.Read(0xFF41)&3 != 3 {
+		t.Fatal("ppu never entered mode 3")
+	}
+	// cpu在模式3无法访问显存，但是DMA可以
+	startDMA(b, 0x80)
+	for i := 0; i <= dmaLength; i++ {
+		b.Tick(4)
+	}
+	b.Write(0xFF40, 0)
+	checkOAM(t, b, 0x20)
+}
+
+// TestDMARoutine cpu在精确模式下执行常见的HRAM DMA例程，等待40次循环后返回
+func TestDMARoutine(t *testing.T) {
+	b := makeTestBus(t, true,
+		0x31, 0xFE, 0xDF, // LD SP, $DFFE
+		0xCD, 0x80, 0xFF, // CALL $FF80
+		0x18, 0xFE, // JR -2
+	)
+	fill(b, 0xC100, dmaLength, 1)
+	for i, x := range []byte{
+		0x3E, 0xC1, // LD A, $C1
+		0xE0, 0x46, // LDH ($46), A
+		0x3E, 0x28, // LD A, 40
+		0x3D,       // DEC A
+		0x20, 0xFD, // JR NZ, -3
+		0xC9, // RET
+	} {
+		b.Write(0xFF80+uint16(i), x)
+	}
+	recorder := cpu.MakeRecordingBus(b)
+	p := cpu.MakeCPU(recorder)
+	p.Reset()
+	p.SetCycleAccurate(true)
+	// LD SP、CALL、例程中的3条指令、40次DEC和JR、RET
+	for i := 0; i < 2+3+80+1; i++ {
+		p.Step(nil)
+	}
+	// RET从WRAM中的栈读取返回地址，DMA必须已经结束
+	n := len(recorder.Cycles)
+	ret := recorder.Cycles[n-4 : n-1]
+	for i, expected := range []cpu.Access{
+		{Addr: 0xFF89, Data: 0xC9, Read: true},
+		{Addr: 0xDFFC, Data: 0x06, Read: true},
+		{Addr: 0xDFFD, Data: 0x00, Read: true},
+	} {
+		if ret[i] != expected {
+			t.Errorf("ret cycle %d: expected %v, got %v", i, expected, ret[i])
+		}
+	}
+	checkOAM(t, b, 1)
+}
@@ -1,22 +1,36 @@
 package cartridge
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+	"time"
+)
 
-type header struct {
-	title            string // 游戏名称，大写ASCII
-	manufacturerCode string // 生产商编号，大写ASCII
-	cgbOnly          bool
-	mbc              byte   // MBC
-	romSize          uint32 // rom 字节大小
-	ramSize          uint32 // ram 字节大小
+// Battery 带电池的MBC，关机后RAM中的存档需要保存
+type Battery interface {
+	HasBattery() bool
+	SaveRAM() []byte     // 需要保存的RAM数据
+	LoadRAM(data []byte) // 从存档恢复RAM
+	RAMModified() bool   // 外部RAM在上一次调用之后是否被写入过，RAM被禁用时丢弃的写入不算
+}
+
+// Clock 带实时时钟的MBC，时钟随模拟的cpu周期前进，而不是宿主机时间
+type Clock interface {
+	Tick(cycles uint64)
+	SyncClock(now time.Time) // 把时钟同步到宿主机时间
+}
 
-	entryPoint []byte
+// Rumble 带震动马达的MBC
+type Rumble interface {
+	SetRumbleHandler(handler func(on bool))
 }
 
 type BasicCartridge struct {
-	h   header
+	h   Header
 	raw []byte
 	mbc MBC // MBC接口
+
+	globalChecksumOK bool
 }
 
 // MBC 接口，所有MBC必须实现读写地址
@@ -25,22 +39,58 @@ type MBC interface {
 	Write(addr uint16, data byte)
 }
 
-func MakeBasicCartridge(raw []byte) BasicCartridge {
+// Load 读取ROM并创建卡带
+// 头部不合法或MBC不支持时返回错误；真实硬件不检查全局校验和，
+// 所以全局校验和错误不是加载错误，由GlobalChecksumOK报告
+func Load(r io.Reader) (*BasicCartridge, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read rom error %w", err)
+	}
+	return MakeBasicCartridge(raw)
+}
+
+// MakeBasicCartridge 从ROM数据创建卡带，错误与Load相同
+func MakeBasicCartridge(raw []byte) (*BasicCartridge, error) {
+	if err := ValidateHeader(raw); err != nil {
+		return nil, err
+	}
 	header := makeHeader(raw)
-	var mbc MBC
-	switch header.mbc {
-	case 0:
-		mbc = makeNoMBC(raw, header.ramSize)
-	case 1:
-		mbc = makeMBC1(raw, header.ramSize)
-	default:
-		panic(fmt.Errorf("unsupported mbc %d", mbc))
+	mbc, err := makeMBC(raw, header)
+	if err != nil {
+		return nil, err
 	}
-	return BasicCartridge{
-		h:   header,
-		raw: raw,
-		mbc: mbc,
+	return &BasicCartridge{
+		h:                header,
+		raw:              raw,
+		mbc:              mbc,
+		globalChecksumOK: globalChecksum(raw) == header.GlobalChecksum,
+	}, nil
+}
+
+func makeMBC(raw []byte, header Header) (MBC, error) {
+	switch header.Type {
+	case 0, 8, 9:
+		return makeNoMBC(raw, header.RAMSize, hasBattery(header.Type)), nil
+	case 1, 2, 3:
+		return makeMBC1(raw, header.RAMSize, hasBattery(header.Type)), nil
+	case 5, 6:
+		return makeMBC2(raw, hasBattery(header.Type)), nil
+	case 0x0F, 0x10, 0x11, 0x12, 0x13:
+		return makeMBC3(raw, header.RAMSize, header.Type), nil
+	case 0x19, 0x1A, 0x1B, 0x1C, 0x1D, 0x1E:
+		return makeMBC5(raw, header.RAMSize, header.Type), nil
+	}
+	return nil, &UnsupportedMBCError{Type: header.Type}
+}
+
+// SupportedType 是否实现了头部0x147的卡带类型，与makeMBC能创建的MBC一致
+func SupportedType(mbcType byte) bool {
+	switch mbcType {
+	case 0, 8, 9, 1, 2, 3, 5, 6, 0x0F, 0x10, 0x11, 0x12, 0x13, 0x19, 0x1A, 0x1B, 0x1C, 0x1D, 0x1E:
+		return true
 	}
+	return false
 }
 
 func (bc *BasicCartridge) Read(addr uint16) byte {
@@ -51,53 +101,74 @@ func (bc *BasicCartridge) Write(addr uint16, data byte) {
 	bc.mbc.Write(addr, data)
 }
 
-func makeHeader(raw []byte) header {
-	title := string(raw[0x134:0x13F])
-	code := string(raw[0x13F:0x143])
-	var cgbOnly bool
-	switch raw[0x143] {
-	case 0x80:
-		cgbOnly = false
-	case 0xC0:
-		cgbOnly = true
+// hasBattery 根据卡带类型(0x147)判断是否有电池
+func hasBattery(mbcType byte) bool {
+	switch mbcType {
+	case 0x03, 0x06, 0x09, 0x0D, 0x0F, 0x10, 0x13, 0x1B, 0x1E, 0x22, 0xFF:
+		return true
+	}
+	return false
+}
+
+// Tick 卡带的实时时钟前进cycles个周期
+func (bc *BasicCartridge) Tick(cycles uint64) {
+	if c, ok := bc.mbc.(Clock); ok {
+		c.Tick(cycles)
+	}
+}
+
+// SyncClock 把卡带的实时时钟同步到宿主机时间，没有时钟的卡带不做任何事
+func (bc *BasicCartridge) SyncClock(now time.Time) {
+	if c, ok := bc.mbc.(Clock); ok {
+		c.SyncClock(now)
 	}
-	// romSize = 32KiB * (1 << value)
-	romSize := uint32(1<<raw[0x148]) * 32 * 1024
-	var ramSize uint32
-	switch raw[0x149] {
-	case 0:
-		ramSize = 0
-	case 1:
-		ramSize = 0
-	case 2:
-		ramSize = 8 * 1024
-	case 3:
-		ramSize = 32 * 1024
-	case 4:
-		ramSize = 128 * 1024
-	case 5:
-		ramSize = 64 * 1024
+}
+
+// SetRumbleHandler 设置震动马达状态变化的回调，没有马达的卡带不做任何事
+func (bc *BasicCartridge) SetRumbleHandler(handler func(on bool)) {
+	if r, ok := bc.mbc.(Rumble); ok {
+		r.SetRumbleHandler(handler)
 	}
-	return header{
-		title:            title,
-		manufacturerCode: code,
-		cgbOnly:          cgbOnly,
-		mbc:              raw[0x147],
-		romSize:          romSize,
-		ramSize:          ramSize,
-		entryPoint:       raw[0x100:0x104],
+}
+
+// HasBattery 卡带是否有电池保存RAM
+func (bc *BasicCartridge) HasBattery() bool {
+	b, ok := bc.mbc.(Battery)
+	return ok && b.HasBattery()
+}
+
+// SaveRAM 获取需要保存的RAM数据，没有电池时返回nil
+func (bc *BasicCartridge) SaveRAM() []byte {
+	if !bc.HasBattery() {
+		return nil
 	}
+	return bc.mbc.(Battery).SaveRAM()
 }
 
-func (bc *BasicCartridge) IsCGBMode() bool {
-	return bc.h.cgbOnly
+// LoadRAM 从存档恢复RAM
+func (bc *BasicCartridge) LoadRAM(data []byte) {
+	if bc.HasBattery() {
+		bc.mbc.(Battery).LoadRAM(data)
+	}
+}
+
+// RAMModified 返回外部RAM在上一次调用之后是否被写入过，用于判断是否需要保存存档
+func (bc *BasicCartridge) RAMModified() bool {
+	b, ok := bc.mbc.(Battery)
+	return ok && b.RAMModified()
+}
+
+// GlobalChecksumOK 全局校验和(0x14E~0x14F)是否正确，错误时卡带仍然可以运行
+func (bc *BasicCartridge) GlobalChecksumOK() bool {
+	return bc.globalChecksumOK
+}
+
+// Header 卡带头部信息
+func (bc *BasicCartridge) Header() Header {
+	return bc.h
 }
 
-func (bc *BasicCartridge) Info() {
-	fmt.Println("title: ", bc.h.title)
-	fmt.Println("manufacturer: ", bc.h.manufacturerCode)
-	fmt.Printf("mbc num: %d\n", bc.h.mbc)
-	fmt.Println("cgb mode: ", bc.h.cgbOnly)
-	fmt.Printf("rom size: %d KiB\n", bc.h.romSize>>10)
-	fmt.Printf("ram size: %d KiB\n", bc.h.ramSize>>10)
+// IsCGBMode 是否以CGB模式运行
+func (bc *BasicCartridge) IsCGBMode() bool {
+	return bc.h.HardwareMode() == CGB
 }
@@ -0,0 +1,185 @@
+package cartridge
+
+import (
+	"errors"
+	"testing"
+)
+
+// SupportedType 与makeMBC分别列出卡带类型，两者必须一致
+func TestSupportedType(t *testing.T) {
+	raw := make([]byte, 2*romBankSize)
+	for i := 0; i < 0x100; i++ {
+		mbcType := byte(i)
+		_, err := makeMBC(raw, Header{Type: mbcType})
+		if supported := SupportedType(mbcType); supported != (err == nil) {
+			t.Errorf("type 0x%02X: SupportedType = %v, makeMBC error = %v", mbcType, supported, err)
+		}
+	}
+}
+
+// RAM被禁用时丢弃的写入不会让存档被重新保存
+func TestRAMModified(t *testing.T) {
+	raw := make([]byte, 2*romBankSize)
+	cart := &BasicCartridge{mbc: makeMBC1(raw, ramBankSize, true)}
+	cart.Write(0xA000, 0x12)
+	if cart.RAMModified() {
+		t.Error("write to disabled RAM marked RAM as modified")
+	}
+	cart.Write(0x0000, 0x0A)
+	cart.Write(0xA000, 0x12)
+	if !cart.RAMModified() {
+		t.Error("write to enabled RAM was not reported")
+	}
+	if cart.RAMModified() {
+		t.Error("RAMModified did not clear the flag")
+	}
+}
+
+// makeTestROM 32KiB没有MBC的卡带，头部logo和校验和正确
+func makeTestROM() []byte {
+	raw := make([]byte, 2*romBankSize)
+	copy(raw[0x104:], nintendoLogo)
+	raw[0x14D] = headerChecksum(raw)
+	return raw
+}
+
+func TestMakeBasicCartridgeErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(raw []byte) []byte
+		check    func(t *testing.T, err error)
+		globalOK bool
+	}{
+		{
+			name:   "too short",
+			modify: func(raw []byte) []byte { return raw[:0x100] },
+			check: func(t *testing.T, err error) {
+				if !errors.Is(err, ErrTooShort) {
+					t.Errorf("error = %v, want ErrTooShort", err)
+				}
+			},
+		},
+		{
+			name: "bad logo",
+			modify: func(raw []byte) []byte {
+				raw[0x104] ^= 0xFF
+				return raw
+			},
+			check: func(t *testing.T, err error) {
+				if !errors.Is(err, ErrBadLogo) {
+					t.Errorf("error = %v, want ErrBadLogo", err)
+				}
+			},
+		},
+		{
+			name: "header checksum",
+			modify: func(raw []byte) []byte {
+				raw[0x14D]++
+				return raw
+			},
+			check: func(t *testing.T, err error) {
+				var e *ChecksumError
+				if !errors.As(err, &e) {
+					t.Fatalf("error = %v, want *ChecksumError", err)
+				}
+				if e.Expected != e.Actual+1 {
+					t.Errorf("expected 0x%02X, actual 0x%02X", e.Expected, e.Actual)
+				}
+			},
+		},
+		{
+			name:   "size mismatch",
+			modify: func(raw []byte) []byte { return append(raw, make([]byte, romBankSize)...) },
+			check: func(t *testing.T, err error) {
+				var e *SizeError
+				if !errors.As(err, &e) {
+					t.Fatalf("error = %v, want *SizeError", err)
+				}
+				if e.Code != 0 || e.Expected != 2*romBankSize || e.Actual != 3*romBankSize {
+					t.Errorf("got %+v", *e)
+				}
+			},
+		},
+		{
+			name: "unknown ram size",
+			modify: func(raw []byte) []byte {
+				raw[0x149] = 6
+				raw[0x14D] = headerChecksum(raw)
+				return raw
+			},
+			check: func(t *testing.T, err error) {
+				var e *HeaderCodeError
+				if !errors.As(err, &e) || e.Addr != 0x149 || e.Code != 6 {
+					t.Errorf("error = %v, want *HeaderCodeError at 0x149", err)
+				}
+			},
+		},
+		{
+			name: "unsupported mbc",
+			modify: func(raw []byte) []byte {
+				raw[0x147] = 0xFC
+				raw[0x14D] = headerChecksum(raw)
+				return raw
+			},
+			check: func(t *testing.T, err error) {
+				var e *UnsupportedMBCError
+				if !errors.As(err, &e) || e.Type != 0xFC {
+					t.Errorf("error = %v, want *UnsupportedMBCError for 0xFC", err)
+				}
+			},
+		},
+		{
+			// 真实硬件不检查全局校验和，只通过GlobalChecksumOK报告
+			name:   "global checksum warning",
+			modify: func(raw []byte) []byte { return raw },
+			check: func(t *testing.T, err error) {
+				if err != nil {
+					t.Errorf("error = %v, want nil", err)
+				}
+			},
+		},
+		{
+			name: "valid",
+			modify: func(raw []byte) []byte {
+				sum := globalChecksum(raw)
+				raw[0x14E], raw[0x14F] = byte(sum>>8), byte(sum)
+				return raw
+			},
+			check: func(t *testing.T, err error) {
+				if err != nil {
+					t.Errorf("error = %v, want nil", err)
+				}
+			},
+			globalOK: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := MakeBasicCartridge(tt.modify(makeTestROM()))
+			tt.check(t, err)
+			if err != nil {
+				if c != nil {
+					t.Error("cartridge returned together with an error")
+				}
+				return
+			}
+			if c.GlobalChecksumOK() != tt.globalOK {
+				t.Errorf("GlobalChecksumOK = %v, want %v", c.GlobalChecksumOK(), tt.globalOK)
+			}
+		})
+	}
+}
+
+// 没有RAM时0xA000~0xBFFF读取到开路总线
+func TestNoMBCOpenBus(t *testing.T) {
+	raw := make([]byte, 2*romBankSize)
+	if got := makeNoMBC(raw, 0, false).Read(0xA000); got != 0xFF {
+		t.Errorf("read without RAM = 0x%02X, want 0xFF", got)
+	}
+	// 2KiB RAM在0xA000~0xBFFF中重复
+	m := makeNoMBC(raw, 0x800, false)
+	m.Write(0xA000, 0x12)
+	if got := m.Read(0xA800); got != 0x12 {
+		t.Errorf("read of mirrored RAM = 0x%02X, want 0x12", got)
+	}
+}
@@ -0,0 +1,53 @@
+package cartridge
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrTooShort ROM长度不足以包含完整的头部(0x0100~0x014F)
+	ErrTooShort = errors.New("rom is too short to contain a cartridge header")
+	// ErrBadLogo 0x0104~0x0133的Nintendo logo与官方数据不一致，启动ROM会拒绝运行
+	ErrBadLogo = errors.New("nintendo logo mismatch")
+)
+
+// SizeError ROM长度与头部0x148的ROM大小不一致
+type SizeError struct {
+	Code     byte // 0x148 的值
+	Expected int  // 头部声明的字节数
+	Actual   int  // 实际的字节数
+}
+
+func (e *SizeError) Error() string {
+	return fmt.Sprintf("rom size mismatch: header code 0x%02X expects %d bytes, got %d", e.Code, e.Expected, e.Actual)
+}
+
+// HeaderCodeError 头部的ROM大小(0x148)或RAM大小(0x149)是未知的编号
+type HeaderCodeError struct {
+	Addr uint16
+	Code byte
+}
+
+func (e *HeaderCodeError) Error() string {
+	return fmt.Sprintf("unknown header code 0x%02X at 0x%04X", e.Code, e.Addr)
+}
+
+// ChecksumError 头部校验和(0x14D)不正确，启动ROM会拒绝运行
+type ChecksumError struct {
+	Expected byte
+	Actual   byte
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("header checksum mismatch: header says 0x%02X, computed 0x%02X", e.Expected, e.Actual)
+}
+
+// UnsupportedMBCError 头部0x147的卡带类型还没有实现
+type UnsupportedMBCError struct {
+	Type byte
+}
+
+func (e *UnsupportedMBCError) Error() string {
+	return fmt.Sprintf("unsupported mbc 0x%02X", e.Type)
+}
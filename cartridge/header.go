@@ -0,0 +1,162 @@
+package cartridge
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CGBSupport 0x143 CGB标志
+type CGBSupport byte
+
+const (
+	CGBNone     CGBSupport = iota // 只支持DMG
+	CGBEnhanced                   // 0x80，支持CGB功能，也可以在DMG上运行
+	CGBOnly                       // 0xC0，只能在CGB上运行
+)
+
+func (s CGBSupport) String() string {
+	switch s {
+	case CGBEnhanced:
+		return "enhanced"
+	case CGBOnly:
+		return "only"
+	}
+	return "none"
+}
+
+// Destination 0x14A 发售地区
+type Destination byte
+
+const (
+	Japan Destination = iota
+	Overseas
+)
+
+func (d Destination) String() string {
+	if d == Japan {
+		return "japan"
+	}
+	return "overseas"
+}
+
+// HardwareMode 运行卡带使用的硬件模式
+type HardwareMode byte
+
+const (
+	DMG HardwareMode = iota
+	CGB
+	SGB
+)
+
+func (m HardwareMode) String() string {
+	switch m {
+	case CGB:
+		return "CGB"
+	case SGB:
+		return "SGB"
+	}
+	return "DMG"
+}
+
+// Header 卡带头部，0x0100~0x014F
+type Header struct {
+	Title            string      // 游戏名称，大写ASCII；新卡带最多11个字符，旧卡带最多15个字符
+	ManufacturerCode string      // 生产商编号，只有新卡带有，大写ASCII
+	CGB              CGBSupport  // 0x143
+	LicenseeCode     string      // 旧卡带是0x14B的两位十六进制数，0x14B为0x33时是0x144~0x145的两个ASCII字符
+	Licensee         string      // 发行商名称，未知时为空
+	SGB              bool        // 0x146 是否支持SGB功能
+	Type             byte        // 0x147 卡带类型，决定MBC和附加硬件
+	ROMSize          uint32      // rom 字节大小
+	RAMSize          uint32      // ram 字节大小
+	Destination      Destination // 0x14A
+	Version          byte        // 0x14C ROM版本号
+	HeaderChecksum   byte        // 0x14D
+	GlobalChecksum   uint16      // 0x14E~0x14F，大端序
+	TitleChecksum    byte        // 0x134~0x143 之和，CGB启动ROM用它为DMG游戏选择调色板
+
+	EntryPoint [4]byte
+}
+
+// 0x14B为0x33时使用0x144~0x145的新发行商编号
+const useNewLicensee = 0x33
+
+// ParseHeader 解析ROM头部，不检查logo和校验和，只要求ROM包含完整的头部
+func ParseHeader(raw []byte) (Header, error) {
+	if len(raw) < headerEnd {
+		return Header{}, ErrTooShort
+	}
+	return makeHeader(raw), nil
+}
+
+func makeHeader(raw []byte) Header {
+	h := Header{
+		Type:           raw[0x147],
+		ROMSize:        romSizeOf(raw[0x148]),
+		RAMSize:        ramSizeOf(raw[0x149]),
+		Destination:    Destination(raw[0x14A] & 1),
+		Version:        raw[0x14C],
+		HeaderChecksum: raw[0x14D],
+		GlobalChecksum: uint16(raw[0x14E])<<8 | uint16(raw[0x14F]),
+	}
+	copy(h.EntryPoint[:], raw[0x100:0x104])
+	for _, b := range raw[0x134:0x144] {
+		h.TitleChecksum += b
+	}
+	switch raw[0x143] {
+	case 0x80:
+		h.CGB = CGBEnhanced
+	case 0xC0:
+		h.CGB = CGBOnly
+	}
+	// 支持CGB的新卡带把标题的最后4个字符作为生产商编号
+	if h.CGB != CGBNone {
+		h.Title = trimTitle(raw[0x134:0x13F])
+		h.ManufacturerCode = trimTitle(raw[0x13F:0x143])
+	} else {
+		h.Title = trimTitle(raw[0x134:0x143])
+	}
+	if raw[0x14B] == useNewLicensee {
+		h.LicenseeCode = string(raw[0x144:0x146])
+		h.Licensee = newLicensees[h.LicenseeCode]
+	} else {
+		h.LicenseeCode = fmt.Sprintf("%02X", raw[0x14B])
+		h.Licensee = oldLicensees[raw[0x14B]]
+	}
+	// 只有使用新发行商编号的卡带，SGB标志才有效
+	h.SGB = raw[0x146] == 0x03 && raw[0x14B] == useNewLicensee
+	return h
+}
+
+// trimTitle 标题不足的部分用0x00填充
+func trimTitle(data []byte) string {
+	if i := strings.IndexByte(string(data), 0); i >= 0 {
+		data = data[:i]
+	}
+	return strings.TrimRight(string(data), " ")
+}
+
+func ramSizeOf(code byte) uint32 {
+	switch code {
+	case 2:
+		return 8 * 1024
+	case 3:
+		return 32 * 1024
+	case 4:
+		return 128 * 1024
+	case 5:
+		return 64 * 1024
+	}
+	return 0
+}
+
+// HardwareMode 根据头部选择硬件模式：支持CGB的卡带使用CGB，支持SGB的卡带使用SGB，其他使用DMG
+func (h Header) HardwareMode() HardwareMode {
+	switch {
+	case h.CGB != CGBNone:
+		return CGB
+	case h.SGB:
+		return SGB
+	}
+	return DMG
+}
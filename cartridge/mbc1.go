@@ -1,49 +1,77 @@
 package cartridge
 
-import "fmt"
+import "bytes"
 
+const (
+	romBankSize = 0x4000
+	ramBankSize = 0x2000
+)
+
+// MBC1 最多2MiB ROM和32KiB RAM
+// bank1是5位的ROM bank编号，bank2是2位的次级寄存器，作为RAM bank编号或ROM bank编号的高位
 type MBC1 struct {
-	raw               []byte
-	romBank0          []byte
-	switchableRomBank []byte
-	romBankSelect     byte
-
-	ramEnabled    bool
-	ramBanks      [][]byte
-	ramBankSelect byte
+	rom      []byte
+	romBanks int // ROM bank数量，用于屏蔽超出ROM大小的bank编号
+
+	ramEnabled bool
+	ram        []byte
+
+	bank1     byte
+	bank2     byte
+	mode      byte // 0: 0x0000~0x3FFF固定bank0，RAM固定bank0；1: bank2同时作用于0x0000~0x3FFF和RAM
+	multicart bool // MBC1M多合一卡带，bank1只有低4位有效
+	battery   bool
+
+	ramModified bool
 }
 
-func makeMBC1(raw []byte, ramSize uint32) *MBC1 {
+func makeMBC1(raw []byte, ramSize uint32, battery bool) *MBC1 {
 	mbc1 := &MBC1{
-		raw:               raw,
-		romBank0:          raw[0:0x4000],
-		switchableRomBank: raw[0x4000:],
-		romBankSelect:     1,
-		ramEnabled:        false,
-		ramBankSelect:     0,
+		rom:       raw,
+		romBanks:  len(raw) / romBankSize,
+		bank1:     1,
+		multicart: isMBC1Multicart(raw),
+		battery:   battery,
 	}
 	if ramSize != 0 {
-		mbc1.ramEnabled = true
-		mbc1.ramBanks = make([][]byte, 4)
-		for i := 0; i < 4; i++ {
-			mbc1.ramBanks[i] = make([]byte, 0x2000)
-		}
+		mbc1.ram = make([]byte, ramSize)
 	}
 	return mbc1
 }
 
+// isMBC1Multicart MBC1M卡带大小为1MiB，并且每个子游戏开头(bank 0x10的倍数)都有Nintendo logo
+func isMBC1Multicart(raw []byte) bool {
+	if len(raw) != 64*romBankSize {
+		return false
+	}
+	logo := raw[0x104:0x134]
+	second := 0x10*romBankSize + 0x104
+	return bytes.Equal(logo, raw[second:second+len(logo)])
+}
+
 func (m *MBC1) Read(addr uint16) byte {
 	switch {
 	case addr <= 0x3FFF:
-		return m.romBank0[addr]
+		var bank int
+		if m.mode == 1 {
+			bank = m.upperBankBits()
+		}
+		return m.readRom(bank, addr)
 	case addr >= 0x4000 && addr <= 0x7FFF: // ROM Bank 01~7F
-		return m.switchableRomBank[addr-0x4000]
+		bank := m.upperBankBits()
+		if m.multicart {
+			bank |= int(m.bank1 & 0xF)
+		} else {
+			bank |= int(m.bank1)
+		}
+		return m.readRom(bank, addr-0x4000)
 	case addr >= 0xA000 && addr <= 0xBFFF: // RAM Bank 0~3
-		if m.ramEnabled {
-			return m.ramBanks[m.ramBankSelect][addr-0xA000]
+		if m.ramEnabled && len(m.ram) > 0 {
+			return m.ram[m.ramOffset(addr)]
 		}
 	}
-	panic(fmt.Errorf("invalid cartridge address: 0x%X", addr))
+	// 没有开启RAM时读取到的是开路总线
+	return 0xFF
 }
 
 func (m *MBC1) Write(addr uint16, data byte) {
@@ -51,15 +79,60 @@ func (m *MBC1) Write(addr uint16, data byte) {
 	case addr <= 0x1FFF: // RAM Enable register
 		m.ramEnabled = data&0xF == 0xA
 	case addr >= 0x2000 && addr <= 0x3FFF: // ROM Bank Number
-		m.switchRomBank(data)
-	case addr >= 0x4000 && addr <= 0x5FFF: // RAM Bank Number
-
+		// bank1为0时会被当作1，所以无法在0x4000~0x7FFF选中bank 0x00/0x20/0x40/0x60
+		m.bank1 = data & 0x1F
+		if m.bank1 == 0 {
+			m.bank1 = 1
+		}
+	case addr >= 0x4000 && addr <= 0x5FFF: // RAM Bank Number 或 ROM Bank Number高位
+		m.bank2 = data & 3
 	case addr >= 0x6000 && addr <= 0x7FFF: // Banking Mode select
+		m.mode = data & 1
+	case addr >= 0xA000 && addr <= 0xBFFF:
+		if m.ramEnabled && len(m.ram) > 0 {
+			m.ram[m.ramOffset(addr)] = data
+			m.ramModified = true
+		}
 	}
 }
 
-func (m *MBC1) switchRomBank(bankSel byte) {
-	m.romBankSelect = bankSel & 0x1F
-	start := uint32(m.romBankSelect) * 0x4000
-	m.switchableRomBank = m.raw[start : start+0x4000]
+// upperBankBits bank2作为ROM bank编号的高位，MBC1M卡带的高位从bit4开始
+func (m *MBC1) upperBankBits() int {
+	if m.multicart {
+		return int(m.bank2) << 4
+	}
+	return int(m.bank2) << 5
+}
+
+// readRom bank编号超出ROM大小时，高位会被忽略
+func (m *MBC1) readRom(bank int, offset uint16) byte {
+	bank %= m.romBanks
+	return m.rom[bank*romBankSize+int(offset)]
+}
+
+func (m *MBC1) ramOffset(addr uint16) int {
+	var bank int
+	if m.mode == 1 {
+		bank = int(m.bank2)
+	}
+	return (bank*ramBankSize + int(addr-0xA000)) % len(m.ram)
+}
+
+func (m *MBC1) HasBattery() bool {
+	return m.battery
+}
+
+// RAMModified 外部RAM在上一次调用之后是否被写入过
+func (m *MBC1) RAMModified() bool {
+	modified := m.ramModified
+	m.ramModified = false
+	return modified
+}
+
+func (m *MBC1) SaveRAM() []byte {
+	return m.ram
+}
+
+func (m *MBC1) LoadRAM(data []byte) {
+	copy(m.ram, data)
 }
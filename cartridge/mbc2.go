@@ -0,0 +1,85 @@
+package cartridge
+
+// MBC2 最多256KiB ROM，内置512x4位RAM
+type MBC2 struct {
+	rom      []byte
+	romBanks int
+	romBank  byte
+
+	ramEnabled bool
+	ram        []byte // 512个半字节，只有低4位有效
+	battery    bool
+
+	ramModified bool
+}
+
+const mbc2RAMSize = 512
+
+func makeMBC2(raw []byte, battery bool) *MBC2 {
+	return &MBC2{
+		rom:      raw,
+		romBanks: len(raw) / romBankSize,
+		romBank:  1,
+		ram:      make([]byte, mbc2RAMSize),
+		battery:  battery,
+	}
+}
+
+func (m *MBC2) Read(addr uint16) byte {
+	switch {
+	case addr <= 0x3FFF:
+		return m.rom[addr]
+	case addr >= 0x4000 && addr <= 0x7FFF: // ROM Bank 01~0F
+		bank := int(m.romBank) % m.romBanks
+		return m.rom[bank*romBankSize+int(addr-0x4000)]
+	case addr >= 0xA000 && addr <= 0xBFFF:
+		// 只有低9位地址有效，512字节在0xA000~0xBFFF中重复，高4位读取为1
+		if m.ramEnabled {
+			return m.ram[addr&0x1FF] | 0xF0
+		}
+	}
+	return 0xFF
+}
+
+func (m *MBC2) Write(addr uint16, data byte) {
+	switch {
+	case addr <= 0x3FFF:
+		// 地址bit8为0时是RAM开关，为1时是ROM bank编号
+		if addr&0x100 == 0 {
+			m.ramEnabled = data&0xF == 0xA
+		} else {
+			m.romBank = data & 0xF
+			if m.romBank == 0 {
+				m.romBank = 1
+			}
+		}
+	case addr >= 0xA000 && addr <= 0xBFFF:
+		if m.ramEnabled {
+			m.ram[addr&0x1FF] = data & 0xF
+			m.ramModified = true
+		}
+	}
+}
+
+func (m *MBC2) HasBattery() bool {
+	return m.battery
+}
+
+// RAMModified 外部RAM在上一次调用之后是否被写入过
+func (m *MBC2) RAMModified() bool {
+	modified := m.ramModified
+	m.ramModified = false
+	return modified
+}
+
+func (m *MBC2) SaveRAM() []byte {
+	return m.ram
+}
+
+func (m *MBC2) LoadRAM(data []byte) {
+	for i := range m.ram {
+		if i < len(data) {
+			m.ram[i] = data[i] & 0xF
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package cartridge
+
+import "time"
+
+// MBC3 最多2MiB ROM和32KiB RAM，部分卡带带有实时时钟
+type MBC3 struct {
+	rom      []byte
+	romBanks int
+	romBank  byte // 7位ROM bank编号
+
+	ramEnabled bool // 同时控制RAM和RTC寄存器
+	ram        []byte
+	ramSelect  byte // 0x00~0x03选择RAM bank，0x08~0x0C选择RTC寄存器
+
+	clock   *rtc  // 没有时钟的卡带为nil
+	savedAt int64 // 存档中记录的保存时间，unix秒，0表示没有记录
+	battery bool
+
+	ramModified bool // RTC寄存器也保存在存档中，写入时同样标记
+}
+
+func makeMBC3(raw []byte, ramSize uint32, mbcType byte) *MBC3 {
+	m := &MBC3{
+		rom:      raw,
+		romBanks: len(raw) / romBankSize,
+		romBank:  1,
+		battery:  hasBattery(mbcType),
+	}
+	if ramSize != 0 {
+		m.ram = make([]byte, ramSize)
+	}
+	if mbcType == 0x0F || mbcType == 0x10 {
+		m.clock = &rtc{}
+	}
+	return m
+}
+
+func (m *MBC3) Read(addr uint16) byte {
+	switch {
+	case addr <= 0x3FFF:
+		return m.rom[addr]
+	case addr >= 0x4000 && addr <= 0x7FFF: // ROM Bank 01~7F
+		bank := int(m.romBank) % m.romBanks
+		return m.rom[bank*romBankSize+int(addr-0x4000)]
+	case addr >= 0xA000 && addr <= 0xBFFF:
+		if !m.ramEnabled {
+			break
+		}
+		if reg, ok := m.rtcRegister(); ok {
+			return m.clock.read(reg)
+		}
+		if m.ramSelect <= 3 && len(m.ram) > 0 {
+			return m.ram[m.ramOffset(addr)]
+		}
+	}
+	return 0xFF
+}
+
+func (m *MBC3) Write(addr uint16, data byte) {
+	switch {
+	case addr <= 0x1FFF: // RAM和RTC开关
+		m.ramEnabled = data&0xF == 0xA
+	case addr >= 0x2000 && addr <= 0x3FFF: // ROM Bank Number
+		m.romBank = data & 0x7F
+		if m.romBank == 0 {
+			m.romBank = 1
+		}
+	case addr >= 0x4000 && addr <= 0x5FFF: // RAM Bank Number 或 RTC寄存器
+		m.ramSelect = data
+	case addr >= 0x6000 && addr <= 0x7FFF: // 锁存时钟
+		if m.clock != nil {
+			m.clock.latch(data)
+		}
+	case addr >= 0xA000 && addr <= 0xBFFF:
+		if !m.ramEnabled {
+			return
+		}
+		if reg, ok := m.rtcRegister(); ok {
+			m.clock.write(reg, data)
+			m.ramModified = true
+		} else if m.ramSelect <= 3 && len(m.ram) > 0 {
+			m.ram[m.ramOffset(addr)] = data
+			m.ramModified = true
+		}
+	}
+}
+
+// rtcRegister 当前选中的RTC寄存器
+func (m *MBC3) rtcRegister() (byte, bool) {
+	if m.clock == nil || m.ramSelect < 0x08 || m.ramSelect > 0x0C {
+		return 0, false
+	}
+	return m.ramSelect - 0x08, true
+}
+
+func (m *MBC3) ramOffset(addr uint16) int {
+	return (int(m.ramSelect)*ramBankSize + int(addr-0xA000)) % len(m.ram)
+}
+
+// Tick 实时时钟随模拟的周期前进
+func (m *MBC3) Tick(cycles uint64) {
+	if m.clock != nil {
+		m.clock.Tick(cycles)
+	}
+}
+
+// SyncClock 把实时时钟同步到宿主机时间
+// 如果存档中记录了保存时间，时钟前进保存之后经过的时间，否则直接设置为宿主机的时分秒
+func (m *MBC3) SyncClock(now time.Time) {
+	if m.clock == nil {
+		return
+	}
+	if m.savedAt != 0 && now.Unix() > m.savedAt {
+		m.clock.advance(uint64(now.Unix() - m.savedAt))
+	} else {
+		m.clock.sync(now)
+	}
+}
+
+func (m *MBC3) HasBattery() bool {
+	return m.battery
+}
+
+// RAMModified 外部RAM在上一次调用之后是否被写入过
+func (m *MBC3) RAMModified() bool {
+	modified := m.ramModified
+	m.ramModified = false
+	return modified
+}
+
+// SaveRAM 有时钟的卡带在RAM数据之后附加RTC footer
+func (m *MBC3) SaveRAM() []byte {
+	if m.clock == nil {
+		return m.ram
+	}
+	data := make([]byte, 0, len(m.ram)+rtcFooterSize)
+	data = append(data, m.ram...)
+	return append(data, m.clock.marshal(time.Now())...)
+}
+
+func (m *MBC3) LoadRAM(data []byte) {
+	copy(m.ram, data)
+	if m.clock != nil && len(data) > len(m.ram) {
+		if savedAt, ok := m.clock.unmarshal(data[len(m.ram):]); ok {
+			m.savedAt = savedAt
+		}
+	}
+}
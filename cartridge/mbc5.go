@@ -0,0 +1,109 @@
+package cartridge
+
+// MBC5 最多8MiB ROM和128KiB RAM，部分卡带带有震动马达
+type MBC5 struct {
+	rom      []byte
+	romBanks int
+	romBank  uint16 // 9位ROM bank编号，可以选择bank 0
+
+	ramEnabled bool
+	ram        []byte
+	ramBank    byte
+
+	battery  bool
+	hasMotor bool
+	motorOn  bool
+	rumble   func(on bool) // 马达状态变化时调用
+
+	ramModified bool
+}
+
+func makeMBC5(raw []byte, ramSize uint32, mbcType byte) *MBC5 {
+	m := &MBC5{
+		rom:      raw,
+		romBanks: len(raw) / romBankSize,
+		romBank:  1,
+		battery:  hasBattery(mbcType),
+		hasMotor: mbcType >= 0x1C && mbcType <= 0x1E,
+	}
+	if ramSize != 0 {
+		m.ram = make([]byte, ramSize)
+	}
+	return m
+}
+
+func (m *MBC5) Read(addr uint16) byte {
+	switch {
+	case addr <= 0x3FFF:
+		return m.rom[addr]
+	case addr >= 0x4000 && addr <= 0x7FFF: // ROM Bank 000~1FF
+		bank := int(m.romBank) % m.romBanks
+		return m.rom[bank*romBankSize+int(addr-0x4000)]
+	case addr >= 0xA000 && addr <= 0xBFFF: // RAM Bank 0~F
+		if m.ramEnabled && len(m.ram) > 0 {
+			return m.ram[m.ramOffset(addr)]
+		}
+	}
+	return 0xFF
+}
+
+func (m *MBC5) Write(addr uint16, data byte) {
+	switch {
+	case addr <= 0x1FFF: // RAM Enable register
+		m.ramEnabled = data&0xF == 0xA
+	case addr >= 0x2000 && addr <= 0x2FFF: // ROM Bank Number 低8位
+		m.romBank = m.romBank&0x100 | uint16(data)
+	case addr >= 0x3000 && addr <= 0x3FFF: // ROM Bank Number 第9位
+		m.romBank = m.romBank&0xFF | uint16(data&1)<<8
+	case addr >= 0x4000 && addr <= 0x5FFF: // RAM Bank Number
+		if m.hasMotor {
+			// 震动卡带的RAM bank编号bit3用于控制马达
+			m.setMotor(data&0x8 != 0)
+			m.ramBank = data & 0x7
+		} else {
+			m.ramBank = data & 0xF
+		}
+	case addr >= 0xA000 && addr <= 0xBFFF:
+		if m.ramEnabled && len(m.ram) > 0 {
+			m.ram[m.ramOffset(addr)] = data
+			m.ramModified = true
+		}
+	}
+}
+
+func (m *MBC5) ramOffset(addr uint16) int {
+	return (int(m.ramBank)*ramBankSize + int(addr-0xA000)) % len(m.ram)
+}
+
+func (m *MBC5) setMotor(on bool) {
+	if on == m.motorOn {
+		return
+	}
+	m.motorOn = on
+	if m.rumble != nil {
+		m.rumble(on)
+	}
+}
+
+func (m *MBC5) SetRumbleHandler(handler func(on bool)) {
+	m.rumble = handler
+}
+
+func (m *MBC5) HasBattery() bool {
+	return m.battery
+}
+
+// RAMModified 外部RAM在上一次调用之后是否被写入过
+func (m *MBC5) RAMModified() bool {
+	modified := m.ramModified
+	m.ramModified = false
+	return modified
+}
+
+func (m *MBC5) SaveRAM() []byte {
+	return m.ram
+}
+
+func (m *MBC5) LoadRAM(data []byte) {
+	copy(m.ram, data)
+}
@@ -5,9 +5,12 @@ type NoMBC struct {
 	rom      []byte
 	ram      []byte
 	usingRam bool
+	battery  bool
+
+	ramModified bool
 }
 
-func makeNoMBC(raw []byte, ramSize uint32) *NoMBC {
+func makeNoMBC(raw []byte, ramSize uint32, battery bool) *NoMBC {
 	var ram []byte
 	if ramSize > 0 {
 		ram = make([]byte, ramSize)
@@ -16,6 +19,7 @@ func makeNoMBC(raw []byte, ramSize uint32) *NoMBC {
 		rom:      raw,
 		ram:      ram,
 		usingRam: ramSize > 0,
+		battery:  battery,
 	}
 }
 
@@ -25,10 +29,11 @@ func (n *NoMBC) Read(addr uint16) byte {
 		return n.rom[addr]
 	case addr >= 0xA000 && addr <= 0xBFFF:
 		if n.usingRam {
-			return n.ram[addr-0xA000]
+			return n.ram[int(addr-0xA000)%len(n.ram)]
 		}
 	}
-	return 0
+	// 没有RAM时读取到的是开路总线
+	return 0xFF
 }
 
 func (n *NoMBC) Write(addr uint16, data byte) {
@@ -36,7 +41,27 @@ func (n *NoMBC) Write(addr uint16, data byte) {
 	case addr <= 0x7FFF:
 	case addr >= 0xA000 && addr <= 0xBFFF:
 		if n.usingRam {
-			n.ram[addr-0xA000] = data
+			n.ram[int(addr-0xA000)%len(n.ram)] = data
+			n.ramModified = true
 		}
 	}
 }
+
+func (n *NoMBC) HasBattery() bool {
+	return n.battery
+}
+
+func (n *NoMBC) SaveRAM() []byte {
+	return n.ram
+}
+
+func (n *NoMBC) LoadRAM(data []byte) {
+	copy(n.ram, data)
+}
+
+// RAMModified 外部RAM在上一次调用之后是否被写入过
+func (n *NoMBC) RAMModified() bool {
+	modified := n.ramModified
+	n.ramModified = false
+	return modified
+}
@@ -0,0 +1,161 @@
+package cartridge
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// 卡带时钟晶振按照模拟的周期计时，每秒4194304个周期，保证每次运行的结果是确定的
+const cyclesPerSecond = 4194304
+
+// RTC 寄存器编号，通过MBC3的RAM bank选择寄存器0x08~0x0C映射
+const (
+	rtcSeconds byte = iota
+	rtcMinutes
+	rtcHours
+	rtcDayLow
+	rtcDayHigh
+)
+
+const (
+	rtcDayHighBit byte = 1 << 0 // DH bit0，天数的第8位
+	rtcHalt       byte = 1 << 6 // DH bit6，时钟暂停
+	rtcDayCarry   byte = 1 << 7 // DH bit7，天数超过511后置位
+)
+
+// rtc MBC3的实时时钟
+type rtc struct {
+	regs    [5]byte // S, M, H, DL, DH
+	latched [5]byte // 锁存后的值，cpu读取的是锁存值
+	cycles  uint64  // 不足一秒的周期
+
+	latchState byte // 上一次写入0x6000~0x7FFF的值，写入0x00后再写入0x01时锁存
+}
+
+// Tick 推进cycles个周期，时钟暂停时不计时
+func (r *rtc) Tick(cycles uint64) {
+	if r.regs[rtcDayHigh]&rtcHalt != 0 {
+		return
+	}
+	r.cycles += cycles
+	for r.cycles >= cyclesPerSecond {
+		r.cycles -= cyclesPerSecond
+		r.advanceSecond()
+	}
+}
+
+// advanceSecond 时钟前进一秒
+// 寄存器可以被写入超出范围的值，此时计数到位宽上限后回到0，不会向高位进位
+func (r *rtc) advanceSecond() {
+	r.regs[rtcSeconds] = (r.regs[rtcSeconds] + 1) & 0x3F
+	if r.regs[rtcSeconds] != 60 {
+		return
+	}
+	r.regs[rtcSeconds] = 0
+	r.regs[rtcMinutes] = (r.regs[rtcMinutes] + 1) & 0x3F
+	if r.regs[rtcMinutes] != 60 {
+		return
+	}
+	r.regs[rtcMinutes] = 0
+	r.regs[rtcHours] = (r.regs[rtcHours] + 1) & 0x1F
+	if r.regs[rtcHours] != 24 {
+		return
+	}
+	r.regs[rtcHours] = 0
+	days := r.days() + 1
+	if days > 0x1FF {
+		days = 0
+		r.regs[rtcDayHigh] |= rtcDayCarry
+	}
+	r.setDays(days)
+}
+
+// advance 时钟前进若干秒
+func (r *rtc) advance(seconds uint64) {
+	if r.regs[rtcDayHigh]&rtcHalt != 0 {
+		return
+	}
+	for ; seconds > 0; seconds-- {
+		r.advanceSecond()
+	}
+}
+
+func (r *rtc) days() uint16 {
+	return uint16(r.regs[rtcDayHigh]&rtcDayHighBit)<<8 | uint16(r.regs[rtcDayLow])
+}
+
+func (r *rtc) setDays(days uint16) {
+	r.regs[rtcDayLow] = byte(days)
+	r.regs[rtcDayHigh] = r.regs[rtcDayHigh]&^rtcDayHighBit | byte(days>>8)&rtcDayHighBit
+}
+
+// latch 写入0x00后再写入0x01，把当前时间复制到锁存寄存器
+func (r *rtc) latch(data byte) {
+	if r.latchState == 0 && data == 1 {
+		r.latched = r.regs
+	}
+	r.latchState = data
+}
+
+func (r *rtc) read(reg byte) byte {
+	return r.latched[reg]
+}
+
+func (r *rtc) write(reg byte, data byte) {
+	switch reg {
+	case rtcSeconds:
+		// 写入秒会重置不足一秒的计数
+		r.cycles = 0
+		r.regs[reg] = data & 0x3F
+	case rtcMinutes:
+		r.regs[reg] = data & 0x3F
+	case rtcHours:
+		r.regs[reg] = data & 0x1F
+	case rtcDayLow:
+		r.regs[reg] = data
+	case rtcDayHigh:
+		r.regs[reg] = data & (rtcDayHighBit | rtcHalt | rtcDayCarry)
+	}
+	r.latched[reg] = r.regs[reg]
+}
+
+// sync 把时分秒设置为宿主机的本地时间，天数保持不变
+func (r *rtc) sync(now time.Time) {
+	r.cycles = 0
+	r.regs[rtcSeconds] = byte(now.Second())
+	r.regs[rtcMinutes] = byte(now.Minute())
+	r.regs[rtcHours] = byte(now.Hour())
+	r.latched = r.regs
+}
+
+// 其他模拟器通用的RTC存档格式：当前的S,M,H,DL,DH和锁存的S,M,H,DL,DH各占4字节，
+// 最后是保存时的unix时间戳，8字节(部分模拟器只写4字节)，全部为小端序
+const (
+	rtcFooterSize      = 48
+	rtcShortFooterSize = 44
+)
+
+func (r *rtc) marshal(now time.Time) []byte {
+	data := make([]byte, rtcFooterSize)
+	for i := range r.regs {
+		binary.LittleEndian.PutUint32(data[i*4:], uint32(r.regs[i]))
+		binary.LittleEndian.PutUint32(data[20+i*4:], uint32(r.latched[i]))
+	}
+	binary.LittleEndian.PutUint64(data[40:], uint64(now.Unix()))
+	return data
+}
+
+// unmarshal 解析RTC footer，返回保存时的时间戳
+func (r *rtc) unmarshal(data []byte) (int64, bool) {
+	if len(data) != rtcFooterSize && len(data) != rtcShortFooterSize {
+		return 0, false
+	}
+	for i := range r.regs {
+		r.regs[i] = byte(binary.LittleEndian.Uint32(data[i*4:]))
+		r.latched[i] = byte(binary.LittleEndian.Uint32(data[20+i*4:]))
+	}
+	if len(data) == rtcShortFooterSize {
+		return int64(binary.LittleEndian.Uint32(data[40:])), true
+	}
+	return int64(binary.LittleEndian.Uint64(data[40:])), true
+}
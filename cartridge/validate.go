@@ -0,0 +1,68 @@
+package cartridge
+
+import "bytes"
+
+// nintendoLogo 0x0104~0x0133，启动ROM会与这段数据比较
+var nintendoLogo = []byte{
+	0xCE, 0xED, 0x66, 0x66, 0xCC, 0x0D, 0x00, 0x0B, 0x03, 0x73, 0x00, 0x83, 0x00, 0x0C, 0x00, 0x0D,
+	0x00, 0x08, 0x11, 0x1F, 0x88, 0x89, 0x00, 0x0E, 0xDC, 0xCC, 0x6E, 0xE6, 0xDD, 0xDD, 0xD9, 0x99,
+	0xBB, 0xBB, 0x67, 0x63, 0x6E, 0x0E, 0xEC, 0xCC, 0xDD, 0xDC, 0x99, 0x9F, 0xBB, 0xB9, 0x33, 0x3E,
+}
+
+const headerEnd = 0x150
+
+// ValidateHeader 检查头部是否完整、logo和头部校验和是否正确、ROM和RAM大小是否合法
+func ValidateHeader(raw []byte) error {
+	if len(raw) < headerEnd {
+		return ErrTooShort
+	}
+	if !bytes.Equal(raw[0x104:0x134], nintendoLogo) {
+		return ErrBadLogo
+	}
+	if sum := headerChecksum(raw); sum != raw[0x14D] {
+		return &ChecksumError{Expected: raw[0x14D], Actual: sum}
+	}
+	if raw[0x148] > 8 {
+		return &HeaderCodeError{Addr: 0x148, Code: raw[0x148]}
+	}
+	if expected := romSizeOf(raw[0x148]); len(raw) != int(expected) {
+		return &SizeError{Code: raw[0x148], Expected: int(expected), Actual: len(raw)}
+	}
+	if raw[0x149] > 5 {
+		return &HeaderCodeError{Addr: 0x149, Code: raw[0x149]}
+	}
+	return nil
+}
+
+// Checksums 计算ROM的头部校验和与全局校验和，用于与头部中记录的值比较
+func Checksums(raw []byte) (byte, uint16, error) {
+	if len(raw) < headerEnd {
+		return 0, 0, ErrTooShort
+	}
+	return headerChecksum(raw), globalChecksum(raw), nil
+}
+
+// headerChecksum 0x134~0x14C的校验和，x = x - byte - 1
+func headerChecksum(raw []byte) byte {
+	var sum byte
+	for _, b := range raw[0x134:0x14D] {
+		sum = sum - b - 1
+	}
+	return sum
+}
+
+// globalChecksum 除0x14E和0x14F之外所有字节的和
+func globalChecksum(raw []byte) uint16 {
+	var sum uint16
+	for i, b := range raw {
+		if i != 0x14E && i != 0x14F {
+			sum += uint16(b)
+		}
+	}
+	return sum
+}
+
+// romSizeOf romSize = 32KiB * (1 << value)
+func romSizeOf(code byte) uint32 {
+	return uint32(1<<code) * 32 * 1024
+}
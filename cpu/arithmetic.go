@@ -263,6 +263,8 @@ func (p *Processor) compare(val1, val2 byte) {
 // h: carry from bit 11
 // c: carry from bit 15
 func addHL(p *Processor, op *Instruction) {
+	// 16位加法分两次8位运算，多一个内部周期
+	p.tickMCycle()
 	original := p.reg16(p.h, p.l)
 	var delta uint16
 	switch op.code {
@@ -285,6 +287,7 @@ func addHL(p *Processor, op *Instruction) {
 
 // INC r16
 func inc16(p *Processor, op *Instruction) {
+	p.tickMCycle()
 	switch op.code {
 	case 0x03:
 		p.writeBC(p.reg16(p.b, p.c) + 1)
@@ -299,6 +302,7 @@ func inc16(p *Processor, op *Instruction) {
 
 // DEC r16
 func dec16(p *Processor, op *Instruction) {
+	p.tickMCycle()
 	switch op.code {
 	case 0x0B:
 		p.writeBC(p.reg16(p.b, p.c) - 1)
@@ -0,0 +1,30 @@
+package cpu
+
+// 卡带入口地址，启动ROM结束时跳转到这里
+const entryPoint uint16 = 0x0100
+
+// F寄存器的标志位，用于构造PostBoot
+const (
+	FlagZ = zeroFlag
+	FlagN = subFlag
+	FlagH = halfCarryFlag
+	FlagC = carryFlag
+)
+
+// PostBoot 启动ROM结束时的寄存器值，随硬件型号和卡带头部变化，由调用者计算
+type PostBoot struct {
+	A, F byte
+	B, C byte
+	D, E byte
+	H, L byte
+}
+
+// SkipBoot 不运行启动ROM，把寄存器设置为启动ROM结束时的值，sp为0xFFFE，pc指向卡带入口
+func (p *Processor) SkipBoot(r PostBoot) {
+	p.pc = entryPoint
+	p.sp = 0xFFFE
+	p.a, p.f = r.A, r.F&0xF0
+	p.b, p.c = r.B, r.C
+	p.d, p.e = r.D, r.E
+	p.h, p.l = r.H, r.L
+}
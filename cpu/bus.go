@@ -0,0 +1,146 @@
+package cpu
+
+import (
+	"fmt"
+
+	"github.com/StellarisJAY/gbgo/interrupt"
+)
+
+// Memory cpu访问的地址空间
+type Memory interface {
+	Read(addr uint16) byte
+	Write(addr uint16, data byte)
+	// Tick 其他设备推进cycles个cpu周期
+	Tick(cycles uint64)
+}
+
+// Interrupts 中断控制器，cpu在指令之间查询和响应中断
+type Interrupts interface {
+	// PendingInterrupt 返回IE和IF中同时置位的最高优先级中断
+	PendingInterrupt() (interrupt.Code, bool)
+	// AcknowledgeInterrupt 开始处理中断时清除IF中的请求位
+	AcknowledgeInterrupt(code interrupt.Code)
+	// InterruptRequested IF中是否有某个中断请求，不考虑IE
+	InterruptRequested(code interrupt.Code) bool
+}
+
+// SpeedSwitch CGB的速度切换
+type SpeedSwitch interface {
+	// SwitchSpeed STOP指令执行时切换速度，没有准备切换时返回false
+	SwitchSpeed() bool
+	// DoubleSpeed 是否处于双倍速模式
+	DoubleSpeed() bool
+}
+
+// Bus cpu通过总线读写内存、推进其他设备、处理中断和切换速度，*bus.Bus实现了该接口
+// 没有中断或双倍速的总线可以嵌入NoInterrupts或SingleSpeed
+type Bus interface {
+	Memory
+	Interrupts
+	SpeedSwitch
+}
+
+// NoInterrupts 没有中断控制器，cpu不会收到中断
+type NoInterrupts struct{}
+
+func (NoInterrupts) PendingInterrupt() (interrupt.Code, bool) { return 0, false }
+func (NoInterrupts) AcknowledgeInterrupt(interrupt.Code)      {}
+func (NoInterrupts) InterruptRequested(interrupt.Code) bool   { return false }
+
+// SingleSpeed 不支持双倍速，cpu始终是普通速度
+type SingleSpeed struct{}
+
+func (SingleSpeed) SwitchSpeed() bool { return false }
+func (SingleSpeed) DoubleSpeed() bool { return false }
+
+// FlatBus 平坦的64KiB RAM，没有任何设备，用于单元测试
+// IE和IF就是0xFFFF和0xFF0F处的数据
+type FlatBus struct {
+	SingleSpeed
+	Data   [0x10000]byte
+	Cycles uint64 // Tick推进的总周期数
+}
+
+func MakeFlatBus() *FlatBus {
+	return &FlatBus{}
+}
+
+func (m *FlatBus) Read(addr uint16) byte {
+	return m.Data[addr]
+}
+
+func (m *FlatBus) Write(addr uint16, data byte) {
+	m.Data[addr] = data
+}
+
+func (m *FlatBus) Tick(cycles uint64) {
+	m.Cycles += cycles
+}
+
+func (m *FlatBus) PendingInterrupt() (interrupt.Code, bool) {
+	pending := m.Data[0xFFFF] & m.Data[0xFF0F] & 0x1F
+	// 最低位的中断优先级最高
+	return interrupt.Code(pending & -pending), pending != 0
+}
+
+func (m *FlatBus) AcknowledgeInterrupt(code interrupt.Code) {
+	m.Data[0xFF0F] &^= byte(code)
+}
+
+func (m *FlatBus) InterruptRequested(code interrupt.Code) bool {
+	return m.Data[0xFF0F]&byte(code) != 0
+}
+
+// Access 一个M-cycle的总线活动，Read和Write都为false时是cpu没有访问总线的内部周期
+type Access struct {
+	Addr  uint16
+	Data  byte
+	Read  bool
+	Write bool
+}
+
+func (a Access) String() string {
+	switch {
+	case a.Read:
+		return fmt.Sprintf("read %04X=%02X", a.Addr, a.Data)
+	case a.Write:
+		return fmt.Sprintf("write %04X=%02X", a.Addr, a.Data)
+	}
+	return "internal"
+}
+
+// RecordingBus 按M-cycle记录cpu的总线活动，用于跟踪和测试
+// 只有精确模式下内部周期的位置是准确的；普通模式下指令结束时才推进，内部周期都记录在访问之后
+// 中断和速度切换直接由被包装的Bus处理
+type RecordingBus struct {
+	Bus
+	Cycles  []Access
+	pending []Access // 上一次Tick之后还没有对应到M-cycle的访问
+}
+
+func MakeRecordingBus(b Bus) *RecordingBus {
+	return &RecordingBus{Bus: b}
+}
+
+func (r *RecordingBus) Read(addr uint16) byte {
+	data := r.Bus.Read(addr)
+	r.pending = append(r.pending, Access{Addr: addr, Data: data, Read: true})
+	return data
+}
+
+func (r *RecordingBus) Write(addr uint16, data byte) {
+	r.Bus.Write(addr, data)
+	r.pending = append(r.pending, Access{Addr: addr, Data: data, Write: true})
+}
+
+// Tick 每个M-cycle对应一次访问，没有访问的M-cycle记录为内部周期
+func (r *RecordingBus) Tick(cycles uint64) {
+	for n := cycles / 4; n > 0; n-- {
+		var access Access
+		if len(r.pending) > 0 {
+			access, r.pending = r.pending[0], r.pending[1:]
+		}
+		r.Cycles = append(r.Cycles, access)
+	}
+	r.Bus.Tick(cycles)
+}
@@ -1,25 +1,35 @@
 package cpu
 
+// jump 修改pc，指令结束后pc不再按照指令长度前进，并按照跳转的周期计算
+func (p *Processor) jump(target uint16) {
+	p.pc = target
+	p.jumped = true
+}
+
 func (p *Processor) conditionalJump(target uint16, condition bool) {
 	if condition {
-		p.pc = target
+		// 读取地址之后还需要一个内部周期写入pc
+		p.tickMCycle()
+		p.jump(target)
 	}
 }
 
 func (p *Processor) conditionalJumpRelative(offset byte, condition bool) {
-	target := int32(p.pc) + int32(int8(offset))
+	// 相对跳转以下一条指令的地址为基准，pc此时指向操作数
+	target := int32(p.pc+1) + int32(int8(offset))
 	if condition {
-		p.pc = uint16(target)
+		p.tickMCycle()
+		p.jump(uint16(target))
 	}
 }
 
 func jp(p *Processor, op *Instruction) {
 	target := p.readOperand16(p.pc, op.mode)
-	p.pc = target
+	p.conditionalJump(target, true)
 }
 
 func jpHL(p *Processor, _ *Instruction) {
-	p.pc = p.reg16(p.h, p.l)
+	p.jump(p.reg16(p.h, p.l))
 }
 
 func jpc(p *Processor, op *Instruction) {
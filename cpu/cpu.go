@@ -2,7 +2,6 @@ package cpu
 
 import (
 	"fmt"
-	"github.com/StellarisJAY/gbgo/bus"
 	"time"
 )
 
@@ -18,11 +17,21 @@ type Processor struct {
 	sp uint16 // stack pointer
 	pc uint16 // program counter
 
-	bus *bus.Bus
+	bus Bus
 
-	pendingInterruptSwitch int // EI和DI都不会立即切换中断状态，都需要等EI和DI之后一条指令执行后才切换状态
+	pendingInterruptSwitch int // EI不会立即开启中断，需要等EI之后的一条指令执行后才切换状态
 	nextInterruptEnable    bool
-	interruptEnabled       bool
+	interruptEnabled       bool // IME
+
+	halted  bool // HALT低功耗状态，等待中断唤醒
+	haltBug bool // IME=0时执行HALT且已有中断等待，下一条指令的第一个字节会被读取两次
+	stopped bool // STOP状态，等待按键唤醒
+
+	jumped bool   // 当前指令是否修改了pc，用于计算条件跳转的周期
+	stall  uint64 // 当前指令之外额外暂停的周期，如切换CGB速度
+
+	cycleAccurate bool   // 每次访问内存时推进其他设备一个M-cycle
+	tickedCycles  uint64 // 当前指令在访问内存时已经推进的周期
 
 	lastTickTime int64
 	cycles       int64
@@ -51,47 +60,96 @@ const (
 	none
 )
 
-func MakeCPU(b *bus.Bus) *Processor {
+func MakeCPU(b Bus) *Processor {
 	return &Processor{
 		bus: b,
 	}
 }
 
+// SetCycleAccurate 开启后每次读写内存都会让其他设备推进一个M-cycle，
+// 指令内部的定时器、PPU和DMA的变化对cpu可见，但是比按指令推进慢
+func (p *Processor) SetCycleAccurate(on bool) {
+	p.cycleAccurate = on
+}
+
 // Tick 每渲染一帧画面CPU tick一次，通过计算两次渲染之间的时间间隔来控制CPU的指令周期
 func (p *Processor) Tick(time int64, callback InstructionCallback) {
 	oldCycles := p.cycles
 	// 上一次tick与当前时间之间的cpu周期数
 	curTickCycles := (time - p.lastTickTime) * cpuFrequencyMilli
+	// CGB双倍速模式下，相同时间内cpu执行两倍的周期
+	if p.bus.DoubleSpeed() {
+		curTickCycles *= 2
+	}
 	for p.cycles <= oldCycles+curTickCycles {
-		oldPc := p.pc
-		opCode := p.readOperand8(p.pc, immediate)
-		p.pc++
-		ins, exists := instructionSet[opCode]
-		if !exists {
-			panic(fmt.Errorf("unknown opcode at 0x%4X:  0x%2X", oldPc, opCode))
-		}
-		ins.execute(p, callback)
-		if oldPc+1 == p.pc {
-			p.pc = oldPc + ins.length
-		}
-		p.cycles += int64(ins.cycles)
-		// EI和DI要等待下一条指令结束才切换interrupt状态
-		if p.pendingInterruptSwitch == 0 {
-			p.pendingInterruptSwitch = -1
-			p.interruptEnabled = p.nextInterruptEnable
-		} else if p.pendingInterruptSwitch > 0 {
-			p.pendingInterruptSwitch -= 1
-		}
+		p.Step(callback)
 	}
 	p.lastTickTime = time
 }
 
-// Reset cpu status
+// Step 执行一条指令或处理一次中断，其他设备推进相同的周期数，返回消耗的cpu周期数
+func (p *Processor) Step(callback InstructionCallback) uint64 {
+	p.tickedCycles = 0
+	cycles := p.step(callback)
+	p.cycles += int64(cycles)
+	// 精确模式下访问内存和内部周期已经推进过，这里只推进剩余的周期
+	if cycles > p.tickedCycles {
+		p.bus.Tick(cycles - p.tickedCycles)
+	}
+	return cycles
+}
+
+// step 执行一条指令或处理一次中断，返回消耗的cpu周期数
+func (p *Processor) step(callback InstructionCallback) uint64 {
+	// HALT和STOP状态下cpu不执行指令，直到被唤醒
+	if p.sleeping() {
+		return 4
+	}
+	// 在两条指令之间处理中断
+	if cycles := p.handleInterrupt(); cycles > 0 {
+		return cycles
+	}
+	oldPc := p.pc
+	opCode := p.readMem8(p.pc)
+	if p.haltBug {
+		// HALT bug: 读取opcode后pc没有自增，opcode之后的一个字节会重复读取
+		p.haltBug = false
+		oldPc--
+	} else {
+		p.pc++
+	}
+	ins := instructionSet[opCode]
+	if opCode == cbPrefix {
+		// CB前缀指令的第二个字节作为操作数读取，pc在指令结束后按长度前进
+		ins = cbInstructionSet[p.readMem8(p.pc)]
+	}
+	if ins == nil {
+		panic(fmt.Errorf("unknown opcode at 0x%04X:  0x%02X", oldPc, opCode))
+	}
+	p.jumped = false
+	ins.execute(p, oldPc, callback)
+	cycles := ins.cycles
+	if p.jumped {
+		cycles = ins.branched
+	} else {
+		p.pc = oldPc + ins.length
+	}
+	cycles += p.stall
+	p.stall = 0
+	// EI要等待下一条指令结束才切换interrupt状态
+	if p.pendingInterruptSwitch == 0 {
+		p.pendingInterruptSwitch = -1
+		p.interruptEnabled = p.nextInterruptEnable
+	} else if p.pendingInterruptSwitch > 0 {
+		p.pendingInterruptSwitch -= 1
+	}
+	return cycles
+}
+
+// Reset 上电状态，从启动ROM的0x0000开始执行；没有启动ROM时需要再调用SkipBoot
 func (p *Processor) Reset() {
-	// entry point
-	p.pc = 0x0100
-	// stack bottom
-	p.sp = 0xFFFE
+	p.pc = 0x0000
+	p.sp = 0x0000
 	p.b, p.c = 0, 0
 	p.d, p.e = 0, 0
 	p.h, p.l = 0, 0
@@ -99,15 +157,17 @@ func (p *Processor) Reset() {
 	p.pendingInterruptSwitch = -1
 	p.nextInterruptEnable = false
 	p.interruptEnabled = false
+	p.halted, p.haltBug, p.stopped = false, false, false
 	p.lastTickTime = time.Now().UnixMilli()
 }
 
+// readOperand8 读取操作数，pc指向opcode之后的第一个字节
 func (p *Processor) readOperand8(pc uint16, mode memoryMode) byte {
 	switch mode {
 	case immediate:
-		return p.readMem8(pc + 1)
+		return p.readMem8(pc)
 	case absolute:
-		addr := p.readMem16(pc + 1)
+		addr := p.readMem16(pc)
 		return p.readMem8(addr)
 	case none:
 		return 0
@@ -119,9 +179,9 @@ func (p *Processor) readOperand8(pc uint16, mode memoryMode) byte {
 func (p *Processor) readOperand16(pc uint16, mode memoryMode) uint16 {
 	switch mode {
 	case immediate:
-		return p.readMem16(pc + 1)
+		return p.readMem16(pc)
 	case absolute:
-		addr := p.readMem16(pc + 1)
+		addr := p.readMem16(pc)
 		return p.readMem16(addr)
 	case none:
 		return 0
@@ -131,15 +191,27 @@ func (p *Processor) readOperand16(pc uint16, mode memoryMode) uint16 {
 }
 
 func (p *Processor) readMem8(addr uint16) byte {
-	return p.bus.ReadMem8(addr)
+	data := p.bus.Read(addr)
+	p.tickMCycle()
+	return data
 }
 
 func (p *Processor) readMem16(addr uint16) uint16 {
-	return p.bus.ReadMem16(addr)
+	low, high := p.readMem8(addr), p.readMem8(addr+1)
+	return uint16(high)<<8 | uint16(low)
 }
 
 func (p *Processor) writeMem8(addr uint16, data byte) {
-	p.bus.WriteMem8(addr, data)
+	p.bus.Write(addr, data)
+	p.tickMCycle()
+}
+
+// tickMCycle 精确模式下，每次访问内存或者指令内部的一个等待周期都让其他设备推进一个M-cycle
+func (p *Processor) tickMCycle() {
+	if p.cycleAccurate {
+		p.bus.Tick(4)
+		p.tickedCycles += 4
+	}
 }
 
 func (p *Processor) writeMem16(addr, data uint16) {
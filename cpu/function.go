@@ -5,7 +5,7 @@ func (p *Processor) conditionalCall(target uint16, condition bool) {
 		// push return address
 		ra := p.pc + 2
 		p.stackPush16(ra)
-		p.pc = target
+		p.jump(target)
 	}
 }
 
@@ -13,7 +13,8 @@ func (p *Processor) conditionalReturn(condition bool) {
 	if condition {
 		// pop return address
 		ra := p.stackPop16()
-		p.pc = ra
+		p.tickMCycle()
+		p.jump(ra)
 	}
 }
 
@@ -42,7 +43,16 @@ func ret(p *Processor, _ *Instruction) {
 	p.conditionalReturn(true)
 }
 
+// RETI 从中断处理程序返回，并立即开启中断
+func reti(p *Processor, _ *Instruction) {
+	p.conditionalReturn(true)
+	p.interruptEnabled = true
+	p.pendingInterruptSwitch = -1
+}
+
 func retc(p *Processor, op *Instruction) {
+	// 检查条件需要一个内部周期，不跳转时也会消耗
+	p.tickMCycle()
 	condition := false
 	switch op.code {
 	case 0xC0:
@@ -0,0 +1,25 @@
+package cpu
+
+// 响应一次中断需要的cpu周期：两个等待周期、压栈PC两个周期、跳转一个周期
+const interruptDispatchCycles = 20
+
+// handleInterrupt 在指令边界检查IME、IE和IF，按优先级处理一个中断
+// 返回处理中断消耗的周期数，没有处理中断时返回0
+func (p *Processor) handleInterrupt() uint64 {
+	if !p.interruptEnabled {
+		return 0
+	}
+	code, ok := p.bus.PendingInterrupt()
+	if !ok {
+		return 0
+	}
+	// 进入中断处理程序时关闭IME，并清除IF中的请求位
+	p.interruptEnabled = false
+	p.pendingInterruptSwitch = -1
+	p.bus.AcknowledgeInterrupt(code)
+	// 两个等待周期(其中一个在压栈时)，当前PC压栈，再用一个周期跳转到中断向量
+	p.tickMCycle()
+	p.restart(code.Vector())
+	p.tickMCycle()
+	return interruptDispatchCycles
+}
@@ -1,13 +1,17 @@
 package cpu
 
+import "fmt"
+
 // Instruction 指令信息
 type Instruction struct {
-	code    byte               // opcode
-	name    string             // 指令别名
-	length  uint16             // 指令长度，字节
-	cycles  uint64             // cpu cycles
-	mode    memoryMode         // 内存访问模式
-	handler instructionHandler // 指令处理函数
+	code     byte               // opcode，CB前缀指令是前缀之后的字节
+	name     string             // 助记符
+	operands string             // 操作数描述，例如 "A, (HL)"
+	length   uint16             // 指令长度，字节，包含CB前缀
+	cycles   uint64             // cpu cycles，条件跳转指令为不跳转时的周期
+	branched uint64             // 发生跳转时的周期，与cycles不同的只有条件跳转、调用和返回
+	mode     memoryMode         // 内存访问模式
+	handler  instructionHandler // 指令处理函数
 }
 
 // ProcessorContext cpu当前的上下文，在callback中使用
@@ -30,21 +34,39 @@ func (ins *Instruction) Name() string {
 	return ins.name
 }
 
+func (ins *Instruction) Operands() string {
+	return ins.operands
+}
+
+// Prefixed 是否是CB前缀指令，此时Code返回前缀之后的字节
+func (ins *Instruction) Prefixed() bool {
+	return cbInstructionSet[ins.code] == ins
+}
+
+// String 指令的汇编形式，例如 "LD A, (HL)"
+func (ins *Instruction) String() string {
+	if ins.operands == "" {
+		return ins.name
+	}
+	return ins.name + " " + ins.operands
+}
+
 type instructionHandler func(p *Processor, op *Instruction)
 type InstructionCallback func(ctx ProcessorContext, op *Instruction)
 
-// execute 执行指令并调用回调函数
-func (ins *Instruction) execute(p *Processor, callback InstructionCallback) {
+// execute 执行指令并调用回调函数，pc为opcode所在的地址
+func (ins *Instruction) execute(p *Processor, pc uint16, callback InstructionCallback) {
 	if callback != nil {
-		callback(p.getContext(), ins)
+		callback(p.getContext(pc), ins)
 	}
 	ins.handler(p, ins)
 }
 
-// getContext 获取当前cpu上下文
-func (p *Processor) getContext() ProcessorContext {
+// getContext 获取当前cpu上下文，pc为当前指令opcode的地址
+// 发生HALT bug时pc没有自增，不能由p.pc推算
+func (p *Processor) getContext(pc uint16) ProcessorContext {
 	return ProcessorContext{
-		PC:     p.pc - 1,
+		PC:     pc,
 		SP:     p.sp,
 		AF:     p.reg16(p.a, p.f),
 		BC:     p.reg16(p.b, p.c),
@@ -54,297 +76,352 @@ func (p *Processor) getContext() ProcessorContext {
 	}
 }
 
-// 指令集，按照指令类型排序
-var instructionSet = map[byte]*Instruction{
+// cbPrefix 之后的一个字节在cbInstructionSet中选择指令
+const cbPrefix byte = 0xCB
+
+// 指令集，按照指令类型排序，未定义的opcode为nil
+var instructionSet = [256]*Instruction{
 	// ld r8, n8
-	0x06: {0x06, "LD", 2, 8, immediate, loadImmediate8},
-	0x0E: {0x0E, "LD", 2, 8, immediate, loadImmediate8},
-	0x16: {0x16, "LD", 2, 8, immediate, loadImmediate8},
-	0x1E: {0x1E, "LD", 2, 8, immediate, loadImmediate8},
-	0x26: {0x26, "LD", 2, 8, immediate, loadImmediate8},
-	0x2E: {0x2E, "LD", 2, 8, immediate, loadImmediate8},
-	0x3E: {0x3E, "LD", 2, 8, immediate, loadImmediate8},
+	0x06: {0x06, "LD", "B, n8", 2, 8, 8, immediate, loadImmediate8},
+	0x0E: {0x0E, "LD", "C, n8", 2, 8, 8, immediate, loadImmediate8},
+	0x16: {0x16, "LD", "D, n8", 2, 8, 8, immediate, loadImmediate8},
+	0x1E: {0x1E, "LD", "E, n8", 2, 8, 8, immediate, loadImmediate8},
+	0x26: {0x26, "LD", "H, n8", 2, 8, 8, immediate, loadImmediate8},
+	0x2E: {0x2E, "LD", "L, n8", 2, 8, 8, immediate, loadImmediate8},
+	0x3E: {0x3E, "LD", "A, n8", 2, 8, 8, immediate, loadImmediate8},
 	// ld r16, n16
-	0x01: {0x01, "LD", 3, 12, immediate, loadImmediate16},
-	0x11: {0x11, "LD", 3, 12, immediate, loadImmediate16},
-	0x21: {0x21, "LD", 3, 12, immediate, loadImmediate16},
-	0x31: {0x31, "LD", 3, 12, immediate, loadImmediate16},
+	0x01: {0x01, "LD", "BC, n16", 3, 12, 12, immediate, loadImmediate16},
+	0x11: {0x11, "LD", "DE, n16", 3, 12, 12, immediate, loadImmediate16},
+	0x21: {0x21, "LD", "HL, n16", 3, 12, 12, immediate, loadImmediate16},
+	0x31: {0x31, "LD", "SP, n16", 3, 12, 12, immediate, loadImmediate16},
 	// ld B, r8
-	0x40: {0x40, "LD", 1, 4, none, loadBFromReg},
-	0x41: {0x41, "LD", 1, 4, none, loadBFromReg},
-	0x42: {0x42, "LD", 1, 4, none, loadBFromReg},
-	0x43: {0x43, "LD", 1, 4, none, loadBFromReg},
-	0x44: {0x44, "LD", 1, 4, none, loadBFromReg},
-	0x45: {0x45, "LD", 1, 4, none, loadBFromReg},
+	0x40: {0x40, "LD", "B, B", 1, 4, 4, none, loadBFromReg},
+	0x41: {0x41, "LD", "B, C", 1, 4, 4, none, loadBFromReg},
+	0x42: {0x42, "LD", "B, D", 1, 4, 4, none, loadBFromReg},
+	0x43: {0x43, "LD", "B, E", 1, 4, 4, none, loadBFromReg},
+	0x44: {0x44, "LD", "B, H", 1, 4, 4, none, loadBFromReg},
+	0x45: {0x45, "LD", "B, L", 1, 4, 4, none, loadBFromReg},
 	// ld C, r8
-	0x48: {0x48, "LD", 1, 4, none, loadCFromReg},
-	0x49: {0x49, "LD", 1, 4, none, loadCFromReg},
-	0x4A: {0x4A, "LD", 1, 4, none, loadCFromReg},
-	0x4B: {0x4B, "LD", 1, 4, none, loadCFromReg},
-	0x4C: {0x4C, "LD", 1, 4, none, loadCFromReg},
-	0x4D: {0x4D, "LD", 1, 4, none, loadCFromReg},
+	0x48: {0x48, "LD", "C, B", 1, 4, 4, none, loadCFromReg},
+	0x49: {0x49, "LD", "C, C", 1, 4, 4, none, loadCFromReg},
+	0x4A: {0x4A, "LD", "C, D", 1, 4, 4, none, loadCFromReg},
+	0x4B: {0x4B, "LD", "C, E", 1, 4, 4, none, loadCFromReg},
+	0x4C: {0x4C, "LD", "C, H", 1, 4, 4, none, loadCFromReg},
+	0x4D: {0x4D, "LD", "C, L", 1, 4, 4, none, loadCFromReg},
 	// ld D, r8
-	0x50: {0x50, "LD", 1, 4, none, loadDFromReg},
-	0x51: {0x51, "LD", 1, 4, none, loadDFromReg},
-	0x52: {0x52, "LD", 1, 4, none, loadDFromReg},
-	0x53: {0x53, "LD", 1, 4, none, loadDFromReg},
-	0x54: {0x54, "LD", 1, 4, none, loadDFromReg},
-	0x55: {0x55, "LD", 1, 4, none, loadDFromReg},
+	0x50: {0x50, "LD", "D, B", 1, 4, 4, none, loadDFromReg},
+	0x51: {0x51, "LD", "D, C", 1, 4, 4, none, loadDFromReg},
+	0x52: {0x52, "LD", "D, D", 1, 4, 4, none, loadDFromReg},
+	0x53: {0x53, "LD", "D, E", 1, 4, 4, none, loadDFromReg},
+	0x54: {0x54, "LD", "D, H", 1, 4, 4, none, loadDFromReg},
+	0x55: {0x55, "LD", "D, L", 1, 4, 4, none, loadDFromReg},
 	// ld E, r8
-	0x58: {0x58, "LD", 1, 4, none, loadEFromReg},
-	0x59: {0x59, "LD", 1, 4, none, loadEFromReg},
-	0x5A: {0x5A, "LD", 1, 4, none, loadEFromReg},
-	0x5B: {0x5B, "LD", 1, 4, none, loadEFromReg},
-	0x5C: {0x5C, "LD", 1, 4, none, loadEFromReg},
-	0x5D: {0x5D, "LD", 1, 4, none, loadEFromReg},
+	0x58: {0x58, "LD", "E, B", 1, 4, 4, none, loadEFromReg},
+	0x59: {0x59, "LD", "E, C", 1, 4, 4, none, loadEFromReg},
+	0x5A: {0x5A, "LD", "E, D", 1, 4, 4, none, loadEFromReg},
+	0x5B: {0x5B, "LD", "E, E", 1, 4, 4, none, loadEFromReg},
+	0x5C: {0x5C, "LD", "E, H", 1, 4, 4, none, loadEFromReg},
+	0x5D: {0x5D, "LD", "E, L", 1, 4, 4, none, loadEFromReg},
 	// ld H, r8
-	0x60: {0x60, "LD", 1, 4, none, loadHFromReg},
-	0x61: {0x61, "LD", 1, 4, none, loadHFromReg},
-	0x62: {0x62, "LD", 1, 4, none, loadHFromReg},
-	0x63: {0x63, "LD", 1, 4, none, loadHFromReg},
-	0x64: {0x64, "LD", 1, 4, none, loadHFromReg},
-	0x65: {0x65, "LD", 1, 4, none, loadHFromReg},
+	0x60: {0x60, "LD", "H, B", 1, 4, 4, none, loadHFromReg},
+	0x61: {0x61, "LD", "H, C", 1, 4, 4, none, loadHFromReg},
+	0x62: {0x62, "LD", "H, D", 1, 4, 4, none, loadHFromReg},
+	0x63: {0x63, "LD", "H, E", 1, 4, 4, none, loadHFromReg},
+	0x64: {0x64, "LD", "H, H", 1, 4, 4, none, loadHFromReg},
+	0x65: {0x65, "LD", "H, L", 1, 4, 4, none, loadHFromReg},
 	// ld L, r8
-	0x68: {0x68, "LD", 1, 4, none, loadLFromReg},
-	0x69: {0x69, "LD", 1, 4, none, loadLFromReg},
-	0x6A: {0x6A, "LD", 1, 4, none, loadLFromReg},
-	0x6B: {0x6B, "LD", 1, 4, none, loadLFromReg},
-	0x6C: {0x6C, "LD", 1, 4, none, loadLFromReg},
-	0x6D: {0x6D, "LD", 1, 4, none, loadLFromReg},
+	0x68: {0x68, "LD", "L, B", 1, 4, 4, none, loadLFromReg},
+	0x69: {0x69, "LD", "L, C", 1, 4, 4, none, loadLFromReg},
+	0x6A: {0x6A, "LD", "L, D", 1, 4, 4, none, loadLFromReg},
+	0x6B: {0x6B, "LD", "L, E", 1, 4, 4, none, loadLFromReg},
+	0x6C: {0x6C, "LD", "L, H", 1, 4, 4, none, loadLFromReg},
+	0x6D: {0x6D, "LD", "L, L", 1, 4, 4, none, loadLFromReg},
 	// ld r8, (HL)
-	0x46: {0x46, "LD", 1, 8, none, loadRegFromHL},
-	0x4E: {0x4E, "LD", 1, 8, none, loadRegFromHL},
-	0x56: {0x56, "LD", 1, 8, none, loadRegFromHL},
-	0x5E: {0x5E, "LD", 1, 8, none, loadRegFromHL},
-	0x66: {0x66, "LD", 1, 8, none, loadRegFromHL},
-	0x6E: {0x6E, "LD", 1, 8, none, loadRegFromHL},
+	0x46: {0x46, "LD", "B, (HL)", 1, 8, 8, none, loadRegFromHL},
+	0x4E: {0x4E, "LD", "C, (HL)", 1, 8, 8, none, loadRegFromHL},
+	0x56: {0x56, "LD", "D, (HL)", 1, 8, 8, none, loadRegFromHL},
+	0x5E: {0x5E, "LD", "E, (HL)", 1, 8, 8, none, loadRegFromHL},
+	0x66: {0x66, "LD", "H, (HL)", 1, 8, 8, none, loadRegFromHL},
+	0x6E: {0x6E, "LD", "L, (HL)", 1, 8, 8, none, loadRegFromHL},
 	// ld (HL), r8
-	0x70: {0x70, "LD", 1, 8, none, storeRegInHL},
-	0x71: {0x71, "LD", 1, 8, none, storeRegInHL},
-	0x72: {0x72, "LD", 1, 8, none, storeRegInHL},
-	0x73: {0x73, "LD", 1, 8, none, storeRegInHL},
-	0x74: {0x74, "LD", 1, 8, none, storeRegInHL},
-	0x75: {0x75, "LD", 1, 8, none, storeRegInHL},
+	0x70: {0x70, "LD", "(HL), B", 1, 8, 8, none, storeRegInHL},
+	0x71: {0x71, "LD", "(HL), C", 1, 8, 8, none, storeRegInHL},
+	0x72: {0x72, "LD", "(HL), D", 1, 8, 8, none, storeRegInHL},
+	0x73: {0x73, "LD", "(HL), E", 1, 8, 8, none, storeRegInHL},
+	0x74: {0x74, "LD", "(HL), H", 1, 8, 8, none, storeRegInHL},
+	0x75: {0x75, "LD", "(HL), L", 1, 8, 8, none, storeRegInHL},
 	// ld A, (NN)
-	0x0A: {0x0A, "LD", 1, 8, none, loadA},
-	0x1A: {0x0A, "LD", 1, 8, none, loadA},
-	0x7E: {0x7E, "LD", 1, 8, none, loadA},
-	0xFA: {0xFA, "LD", 3, 16, none, loadA},
+	0x0A: {0x0A, "LD", "A, (BC)", 1, 8, 8, none, loadA},
+	0x1A: {0x1A, "LD", "A, (DE)", 1, 8, 8, none, loadA},
+	0x7E: {0x7E, "LD", "A, (HL)", 1, 8, 8, none, loadA},
+	0xFA: {0xFA, "LD", "A, (a16)", 3, 16, 16, none, loadA},
 	// ld (N), A
-	0x02: {0x02, "LD", 1, 8, none, storeA},
-	0x12: {0x12, "LD", 1, 8, none, storeA},
-	0x77: {0x77, "LD", 1, 8, none, storeA},
-	0xEA: {0xEA, "LD", 3, 16, none, storeA},
+	0x02: {0x02, "LD", "(BC), A", 1, 8, 8, none, storeA},
+	0x12: {0x12, "LD", "(DE), A", 1, 8, 8, none, storeA},
+	0x77: {0x77, "LD", "(HL), A", 1, 8, 8, none, storeA},
+	0xEA: {0xEA, "LD", "(a16), A", 3, 16, 16, none, storeA},
 	// ld r8, A
-	0x47: {0x47, "LD", 1, 4, none, storeAInReg},
-	0x4F: {0x4F, "LD", 1, 4, none, storeAInReg},
-	0x57: {0x57, "LD", 1, 4, none, storeAInReg},
-	0x5F: {0x5F, "LD", 1, 4, none, storeAInReg},
-	0x67: {0x67, "LD", 1, 4, none, storeAInReg},
-	0x6F: {0x6F, "LD", 1, 4, none, storeAInReg},
+	0x47: {0x47, "LD", "B, A", 1, 4, 4, none, storeAInReg},
+	0x4F: {0x4F, "LD", "C, A", 1, 4, 4, none, storeAInReg},
+	0x57: {0x57, "LD", "D, A", 1, 4, 4, none, storeAInReg},
+	0x5F: {0x5F, "LD", "E, A", 1, 4, 4, none, storeAInReg},
+	0x67: {0x67, "LD", "H, A", 1, 4, 4, none, storeAInReg},
+	0x6F: {0x6F, "LD", "L, A", 1, 4, 4, none, storeAInReg},
 	// ld A, r8
-	0x78: {0x78, "LD", 1, 4, none, loadAFromReg},
-	0x79: {0x79, "LD", 1, 4, none, loadAFromReg},
-	0x7A: {0x7A, "LD", 1, 4, none, loadAFromReg},
-	0x7B: {0x7B, "LD", 1, 4, none, loadAFromReg},
-	0x7C: {0x7C, "LD", 1, 4, none, loadAFromReg},
-	0x7D: {0x7D, "LD", 1, 4, none, loadAFromReg},
-	0x7F: {0x7F, "LD", 1, 4, none, loadAFromReg},
+	0x78: {0x78, "LD", "A, B", 1, 4, 4, none, loadAFromReg},
+	0x79: {0x79, "LD", "A, C", 1, 4, 4, none, loadAFromReg},
+	0x7A: {0x7A, "LD", "A, D", 1, 4, 4, none, loadAFromReg},
+	0x7B: {0x7B, "LD", "A, E", 1, 4, 4, none, loadAFromReg},
+	0x7C: {0x7C, "LD", "A, H", 1, 4, 4, none, loadAFromReg},
+	0x7D: {0x7D, "LD", "A, L", 1, 4, 4, none, loadAFromReg},
+	0x7F: {0x7F, "LD", "A, A", 1, 4, 4, none, loadAFromReg},
 	// ld (HL), n8
-	0x36: {0x36, "LD", 2, 12, immediate, storeImmediateInHL},
+	0x36: {0x36, "LD", "(HL), n8", 2, 12, 12, immediate, storeImmediateInHL},
 	// read IO Port
-	0xF0: {0xF0, "LD", 2, 12, immediate, readIOPort},
-	0xF2: {0xF2, "LD", 1, 8, none, readIOPort},
+	0xF0: {0xF0, "LDH", "A, (a8)", 2, 12, 12, immediate, readIOPort},
+	0xF2: {0xF2, "LD", "A, (C)", 1, 8, 8, none, readIOPort},
 	// write IO Port
-	0xE0: {0xE0, "LD", 2, 12, immediate, writeIOPort},
-	0xE2: {0xE2, "LD", 1, 8, none, writeIOPort},
+	0xE0: {0xE0, "LDH", "(a8), A", 2, 12, 12, immediate, writeIOPort},
+	0xE2: {0xE2, "LD", "(C), A", 1, 8, 8, none, writeIOPort},
 	// ldi
-	0x22: {0x22, "LDI", 1, 8, none, ldi},
-	0x2A: {0x2A, "LDI", 1, 8, none, ldi},
+	0x22: {0x22, "LD", "(HL+), A", 1, 8, 8, none, ldi},
+	0x2A: {0x2A, "LD", "A, (HL+)", 1, 8, 8, none, ldi},
 	// ldd
-	0x32: {0x32, "LDD", 1, 8, none, ldd},
-	0x3A: {0x3A, "LDD", 1, 8, none, ldd},
+	0x32: {0x32, "LD", "(HL-), A", 1, 8, 8, none, ldd},
+	0x3A: {0x3A, "LD", "A, (HL-)", 1, 8, 8, none, ldd},
 	// ld SP, HL
-	0xF9: {0xF9, "LD", 1, 8, none, loadSP},
+	0xF9: {0xF9, "LD", "SP, HL", 1, 8, 8, none, loadSP},
+	// ld HL, SP + e8
+	0xF8: {0xF8, "LD", "HL, SP + e8", 2, 12, 12, immediate, loadHLFromSP},
 	// ld (nn), SP
-	0x08: {0x08, "LD", 3, 20, immediate, saveSP},
+	0x08: {0x08, "LD", "(a16), SP", 3, 20, 20, immediate, saveSP},
 	// stack push
-	0xC5: {0xC5, "PUSH", 1, 12, none, pushReg},
-	0xD5: {0xD5, "PUSH", 1, 12, none, pushReg},
-	0xE5: {0xE5, "PUSH", 1, 12, none, pushReg},
-	0xF5: {0xF5, "PUSH", 1, 12, none, pushReg},
+	0xC5: {0xC5, "PUSH", "BC", 1, 16, 16, none, pushReg},
+	0xD5: {0xD5, "PUSH", "DE", 1, 16, 16, none, pushReg},
+	0xE5: {0xE5, "PUSH", "HL", 1, 16, 16, none, pushReg},
+	0xF5: {0xF5, "PUSH", "AF", 1, 16, 16, none, pushReg},
 	// stack pop
-	0xC1: {0xC1, "PUSH", 1, 12, none, popReg},
-	0xD1: {0xD1, "PUSH", 1, 12, none, popReg},
-	0xE1: {0xE1, "PUSH", 1, 12, none, popReg},
-	0xF1: {0xF1, "PUSH", 1, 12, none, popReg},
+	0xC1: {0xC1, "POP", "BC", 1, 12, 12, none, popReg},
+	0xD1: {0xD1, "POP", "DE", 1, 12, 12, none, popReg},
+	0xE1: {0xE1, "POP", "HL", 1, 12, 12, none, popReg},
+	0xF1: {0xF1, "POP", "AF", 1, 12, 12, none, popReg},
 	// logic
 	// AND A, N
-	0xA0: {0xA0, "AND", 1, 4, none, andWithA},
-	0xA1: {0xA1, "AND", 1, 4, none, andWithA},
-	0xA2: {0xA2, "AND", 1, 4, none, andWithA},
-	0xA3: {0xA3, "AND", 1, 4, none, andWithA},
-	0xA4: {0xA4, "AND", 1, 4, none, andWithA},
-	0xA5: {0xA5, "AND", 1, 4, none, andWithA},
-	0xA6: {0xA6, "AND", 1, 8, none, andWithA},
-	0xA7: {0xA7, "AND", 1, 4, none, andWithA},
-	0xE6: {0xE6, "AND", 2, 8, immediate, andWithA},
+	0xA0: {0xA0, "AND", "A, B", 1, 4, 4, none, andWithA},
+	0xA1: {0xA1, "AND", "A, C", 1, 4, 4, none, andWithA},
+	0xA2: {0xA2, "AND", "A, D", 1, 4, 4, none, andWithA},
+	0xA3: {0xA3, "AND", "A, E", 1, 4, 4, none, andWithA},
+	0xA4: {0xA4, "AND", "A, H", 1, 4, 4, none, andWithA},
+	0xA5: {0xA5, "AND", "A, L", 1, 4, 4, none, andWithA},
+	0xA6: {0xA6, "AND", "A, (HL)", 1, 8, 8, none, andWithA},
+	0xA7: {0xA7, "AND", "A, A", 1, 4, 4, none, andWithA},
+	0xE6: {0xE6, "AND", "A, n8", 2, 8, 8, immediate, andWithA},
 	// OR A, N
-	0xB0: {0xB0, "OR", 1, 4, none, orWithA},
-	0xB1: {0xB1, "OR", 1, 4, none, orWithA},
-	0xB2: {0xB2, "OR", 1, 4, none, orWithA},
-	0xB3: {0xB3, "OR", 1, 4, none, orWithA},
-	0xB4: {0xB4, "OR", 1, 4, none, orWithA},
-	0xB5: {0xB5, "OR", 1, 4, none, orWithA},
-	0xB6: {0xB6, "OR", 1, 8, none, orWithA},
-	0xB7: {0xB7, "OR", 1, 4, none, orWithA},
-	0xF6: {0xF6, "OR", 2, 8, immediate, orWithA},
+	0xB0: {0xB0, "OR", "A, B", 1, 4, 4, none, orWithA},
+	0xB1: {0xB1, "OR", "A, C", 1, 4, 4, none, orWithA},
+	0xB2: {0xB2, "OR", "A, D", 1, 4, 4, none, orWithA},
+	0xB3: {0xB3, "OR", "A, E", 1, 4, 4, none, orWithA},
+	0xB4: {0xB4, "OR", "A, H", 1, 4, 4, none, orWithA},
+	0xB5: {0xB5, "OR", "A, L", 1, 4, 4, none, orWithA},
+	0xB6: {0xB6, "OR", "A, (HL)", 1, 8, 8, none, orWithA},
+	0xB7: {0xB7, "OR", "A, A", 1, 4, 4, none, orWithA},
+	0xF6: {0xF6, "OR", "A, n8", 2, 8, 8, immediate, orWithA},
 	// XOR A, N
-	0xA8: {0xA8, "XOR", 1, 4, none, xorWithA},
-	0xA9: {0xA9, "XOR", 1, 4, none, xorWithA},
-	0xAA: {0xAA, "XOR", 1, 4, none, xorWithA},
-	0xAB: {0xAB, "XOR", 1, 4, none, xorWithA},
-	0xAC: {0xAC, "XOR", 1, 4, none, xorWithA},
-	0xAD: {0xAD, "XOR", 1, 4, none, xorWithA},
-	0xAE: {0xAE, "XOR", 1, 8, none, xorWithA},
-	0xAF: {0xAF, "XOR", 1, 4, none, xorWithA},
-	0xEE: {0xEE, "XOR", 2, 8, immediate, xorWithA},
+	0xA8: {0xA8, "XOR", "A, B", 1, 4, 4, none, xorWithA},
+	0xA9: {0xA9, "XOR", "A, C", 1, 4, 4, none, xorWithA},
+	0xAA: {0xAA, "XOR", "A, D", 1, 4, 4, none, xorWithA},
+	0xAB: {0xAB, "XOR", "A, E", 1, 4, 4, none, xorWithA},
+	0xAC: {0xAC, "XOR", "A, H", 1, 4, 4, none, xorWithA},
+	0xAD: {0xAD, "XOR", "A, L", 1, 4, 4, none, xorWithA},
+	0xAE: {0xAE, "XOR", "A, (HL)", 1, 8, 8, none, xorWithA},
+	0xAF: {0xAF, "XOR", "A, A", 1, 4, 4, none, xorWithA},
+	0xEE: {0xEE, "XOR", "A, n8", 2, 8, 8, immediate, xorWithA},
 	// INC N
-	0x34: {0x34, "INC", 1, 12, none, inc},
-	0x04: {0x04, "INC", 1, 4, none, inc},
-	0x0C: {0x0C, "INC", 1, 4, none, inc},
-	0x14: {0x14, "INC", 1, 4, none, inc},
-	0x1C: {0x1C, "INC", 1, 4, none, inc},
-	0x24: {0x24, "INC", 1, 4, none, inc},
-	0x2C: {0x2C, "INC", 1, 4, none, inc},
-	0x3C: {0x3C, "INC", 1, 4, none, inc},
+	0x04: {0x04, "INC", "B", 1, 4, 4, none, inc},
+	0x0C: {0x0C, "INC", "C", 1, 4, 4, none, inc},
+	0x14: {0x14, "INC", "D", 1, 4, 4, none, inc},
+	0x1C: {0x1C, "INC", "E", 1, 4, 4, none, inc},
+	0x24: {0x24, "INC", "H", 1, 4, 4, none, inc},
+	0x2C: {0x2C, "INC", "L", 1, 4, 4, none, inc},
+	0x34: {0x34, "INC", "(HL)", 1, 12, 12, none, inc},
+	0x3C: {0x3C, "INC", "A", 1, 4, 4, none, inc},
 	// DEC N
-	0x35: {0x35, "DEC", 1, 12, none, dec},
-	0x05: {0x05, "DEC", 1, 4, none, dec},
-	0x0D: {0x0D, "DEC", 1, 4, none, dec},
-	0x15: {0x15, "DEC", 1, 4, none, dec},
-	0x1D: {0x1D, "DEC", 1, 4, none, dec},
-	0x25: {0x25, "DEC", 1, 4, none, dec},
-	0x2D: {0x2D, "DEC", 1, 4, none, dec},
-	0x3D: {0x3D, "DEC", 1, 4, none, dec},
+	0x05: {0x05, "DEC", "B", 1, 4, 4, none, dec},
+	0x0D: {0x0D, "DEC", "C", 1, 4, 4, none, dec},
+	0x15: {0x15, "DEC", "D", 1, 4, 4, none, dec},
+	0x1D: {0x1D, "DEC", "E", 1, 4, 4, none, dec},
+	0x25: {0x25, "DEC", "H", 1, 4, 4, none, dec},
+	0x2D: {0x2D, "DEC", "L", 1, 4, 4, none, dec},
+	0x35: {0x35, "DEC", "(HL)", 1, 12, 12, none, dec},
+	0x3D: {0x3D, "DEC", "A", 1, 4, 4, none, dec},
 	// ADD A, N
-	0x80: {0x80, "ADD", 1, 4, none, addA},
-	0x81: {0x81, "ADD", 1, 4, none, addA},
-	0x82: {0x82, "ADD", 1, 4, none, addA},
-	0x83: {0x83, "ADD", 1, 4, none, addA},
-	0x84: {0x84, "ADD", 1, 4, none, addA},
-	0x85: {0x85, "ADD", 1, 4, none, addA},
-	0x86: {0x86, "ADD", 1, 8, none, addA},
-	0x87: {0x87, "ADD", 1, 4, none, addA},
-	0xC6: {0xC6, "ADD", 1, 8, immediate, addA},
+	0x80: {0x80, "ADD", "A, B", 1, 4, 4, none, addA},
+	0x81: {0x81, "ADD", "A, C", 1, 4, 4, none, addA},
+	0x82: {0x82, "ADD", "A, D", 1, 4, 4, none, addA},
+	0x83: {0x83, "ADD", "A, E", 1, 4, 4, none, addA},
+	0x84: {0x84, "ADD", "A, H", 1, 4, 4, none, addA},
+	0x85: {0x85, "ADD", "A, L", 1, 4, 4, none, addA},
+	0x86: {0x86, "ADD", "A, (HL)", 1, 8, 8, none, addA},
+	0x87: {0x87, "ADD", "A, A", 1, 4, 4, none, addA},
+	0xC6: {0xC6, "ADD", "A, n8", 2, 8, 8, immediate, addA},
 	// ADC A, N
-	0x88: {0x88, "ADC", 1, 4, none, addAWithCarry},
-	0x89: {0x89, "ADC", 1, 4, none, addAWithCarry},
-	0x8A: {0x8A, "ADC", 1, 4, none, addAWithCarry},
-	0x8B: {0x8B, "ADC", 1, 4, none, addAWithCarry},
-	0x8C: {0x8C, "ADC", 1, 4, none, addAWithCarry},
-	0x8D: {0x8D, "ADC", 1, 4, none, addAWithCarry},
-	0x8E: {0x8E, "ADC", 1, 8, none, addAWithCarry},
-	0x8F: {0x8F, "ADC", 1, 4, none, addAWithCarry},
-	0xCE: {0xCE, "ADC", 1, 8, immediate, addAWithCarry},
+	0x88: {0x88, "ADC", "A, B", 1, 4, 4, none, addAWithCarry},
+	0x89: {0x89, "ADC", "A, C", 1, 4, 4, none, addAWithCarry},
+	0x8A: {0x8A, "ADC", "A, D", 1, 4, 4, none, addAWithCarry},
+	0x8B: {0x8B, "ADC", "A, E", 1, 4, 4, none, addAWithCarry},
+	0x8C: {0x8C, "ADC", "A, H", 1, 4, 4, none, addAWithCarry},
+	0x8D: {0x8D, "ADC", "A, L", 1, 4, 4, none, addAWithCarry},
+	0x8E: {0x8E, "ADC", "A, (HL)", 1, 8, 8, none, addAWithCarry},
+	0x8F: {0x8F, "ADC", "A, A", 1, 4, 4, none, addAWithCarry},
+	0xCE: {0xCE, "ADC", "A, n8", 2, 8, 8, immediate, addAWithCarry},
 	// SUB A, N
-	0x90: {0x90, "SUB", 1, 4, none, subA},
-	0x91: {0x91, "SUB", 1, 4, none, subA},
-	0x92: {0x92, "SUB", 1, 4, none, subA},
-	0x93: {0x93, "SUB", 1, 4, none, subA},
-	0x94: {0x94, "SUB", 1, 4, none, subA},
-	0x95: {0x95, "SUB", 1, 4, none, subA},
-	0x96: {0x96, "SUB", 1, 8, none, subA},
-	0x97: {0x97, "SUB", 1, 4, none, subA},
-	0xD6: {0xD6, "SUB", 1, 8, immediate, subA},
+	0x90: {0x90, "SUB", "A, B", 1, 4, 4, none, subA},
+	0x91: {0x91, "SUB", "A, C", 1, 4, 4, none, subA},
+	0x92: {0x92, "SUB", "A, D", 1, 4, 4, none, subA},
+	0x93: {0x93, "SUB", "A, E", 1, 4, 4, none, subA},
+	0x94: {0x94, "SUB", "A, H", 1, 4, 4, none, subA},
+	0x95: {0x95, "SUB", "A, L", 1, 4, 4, none, subA},
+	0x96: {0x96, "SUB", "A, (HL)", 1, 8, 8, none, subA},
+	0x97: {0x97, "SUB", "A, A", 1, 4, 4, none, subA},
+	0xD6: {0xD6, "SUB", "A, n8", 2, 8, 8, immediate, subA},
 	// SBC A, N
-	0x98: {0x98, "SBC", 1, 4, none, subAWithCarry},
-	0x99: {0x99, "SBC", 1, 4, none, subAWithCarry},
-	0x9A: {0x9A, "SBC", 1, 4, none, subAWithCarry},
-	0x9B: {0x9B, "SBC", 1, 4, none, subAWithCarry},
-	0x9C: {0x9C, "SBC", 1, 4, none, subAWithCarry},
-	0x9D: {0x9D, "SBC", 1, 4, none, subAWithCarry},
-	0x9E: {0x9E, "SBC", 1, 8, none, subAWithCarry},
-	0x9F: {0x9F, "SBC", 1, 4, none, subAWithCarry},
-	0xDE: {0xDE, "SBC", 1, 8, immediate, subAWithCarry},
+	0x98: {0x98, "SBC", "A, B", 1, 4, 4, none, subAWithCarry},
+	0x99: {0x99, "SBC", "A, C", 1, 4, 4, none, subAWithCarry},
+	0x9A: {0x9A, "SBC", "A, D", 1, 4, 4, none, subAWithCarry},
+	0x9B: {0x9B, "SBC", "A, E", 1, 4, 4, none, subAWithCarry},
+	0x9C: {0x9C, "SBC", "A, H", 1, 4, 4, none, subAWithCarry},
+	0x9D: {0x9D, "SBC", "A, L", 1, 4, 4, none, subAWithCarry},
+	0x9E: {0x9E, "SBC", "A, (HL)", 1, 8, 8, none, subAWithCarry},
+	0x9F: {0x9F, "SBC", "A, A", 1, 4, 4, none, subAWithCarry},
+	0xDE: {0xDE, "SBC", "A, n8", 2, 8, 8, immediate, subAWithCarry},
 	// CP A, N
-	0xB8: {0xB8, "CP", 1, 4, none, compareA},
-	0xB9: {0xB9, "CP", 1, 4, none, compareA},
-	0xBA: {0xBA, "CP", 1, 4, none, compareA},
-	0xBB: {0xBB, "CP", 1, 4, none, compareA},
-	0xBC: {0xBC, "CP", 1, 4, none, compareA},
-	0xBD: {0xBD, "CP", 1, 4, none, compareA},
-	0xBE: {0xBE, "CP", 1, 8, none, compareA},
-	0xBF: {0xBF, "CP", 1, 4, none, compareA},
-	0xFE: {0xFE, "CP", 1, 8, immediate, compareA},
+	0xB8: {0xB8, "CP", "A, B", 1, 4, 4, none, compareA},
+	0xB9: {0xB9, "CP", "A, C", 1, 4, 4, none, compareA},
+	0xBA: {0xBA, "CP", "A, D", 1, 4, 4, none, compareA},
+	0xBB: {0xBB, "CP", "A, E", 1, 4, 4, none, compareA},
+	0xBC: {0xBC, "CP", "A, H", 1, 4, 4, none, compareA},
+	0xBD: {0xBD, "CP", "A, L", 1, 4, 4, none, compareA},
+	0xBE: {0xBE, "CP", "A, (HL)", 1, 8, 8, none, compareA},
+	0xBF: {0xBF, "CP", "A, A", 1, 4, 4, none, compareA},
+	0xFE: {0xFE, "CP", "A, n8", 2, 8, 8, immediate, compareA},
 	// CPL
-	0x2F: {0x2F, "CPL", 1, 4, none, cpl},
+	0x2F: {0x2F, "CPL", "", 1, 4, 4, none, cpl},
 	// DAA
-	0x27: {0x27, "DAA", 1, 4, none, daa},
+	0x27: {0x27, "DAA", "", 1, 4, 4, none, daa},
 	// ADD HL, N
-	0x09: {0x09, "ADD_HL", 1, 8, none, addHL},
-	0x19: {0x19, "ADD_HL", 1, 8, none, addHL},
-	0x29: {0x29, "ADD_HL", 1, 8, none, addHL},
-	0x39: {0x39, "ADD_HL", 1, 8, none, addHL},
+	0x09: {0x09, "ADD", "HL, BC", 1, 8, 8, none, addHL},
+	0x19: {0x19, "ADD", "HL, DE", 1, 8, 8, none, addHL},
+	0x29: {0x29, "ADD", "HL, HL", 1, 8, 8, none, addHL},
+	0x39: {0x39, "ADD", "HL, SP", 1, 8, 8, none, addHL},
 	// ADD SP, n8
-	0xE8: {0xE8, "ADD_SP", 2, 16, immediate, addSP},
+	0xE8: {0xE8, "ADD", "SP, e8", 2, 16, 16, immediate, addSP},
 	// INC r16
-	0x03: {0x03, "INC", 1, 8, none, inc16},
-	0x13: {0x13, "INC", 1, 8, none, inc16},
-	0x23: {0x23, "INC", 1, 8, none, inc16},
-	0x33: {0x33, "INC", 1, 8, none, inc16},
+	0x03: {0x03, "INC", "BC", 1, 8, 8, none, inc16},
+	0x13: {0x13, "INC", "DE", 1, 8, 8, none, inc16},
+	0x23: {0x23, "INC", "HL", 1, 8, 8, none, inc16},
+	0x33: {0x33, "INC", "SP", 1, 8, 8, none, inc16},
 	// DEC r16
-	0x0B: {0x0B, "DEC", 1, 8, none, dec16},
-	0x1B: {0x1B, "DEC", 1, 8, none, dec16},
-	0x2B: {0x2B, "DEC", 1, 8, none, dec16},
-	0x3B: {0x3B, "DEC", 1, 8, none, dec16},
+	0x0B: {0x0B, "DEC", "BC", 1, 8, 8, none, dec16},
+	0x1B: {0x1B, "DEC", "DE", 1, 8, 8, none, dec16},
+	0x2B: {0x2B, "DEC", "HL", 1, 8, 8, none, dec16},
+	0x3B: {0x3B, "DEC", "SP", 1, 8, 8, none, dec16},
 	// Rotate A
-	0x07: {0x07, "RLCA", 1, 4, none, rotateA},
-	0x17: {0x17, "RLA", 1, 4, none, rotateA},
-	0x0f: {0x0f, "RRCA", 1, 4, none, rotateA},
-	0x1f: {0x1f, "RRA", 1, 4, none, rotateA},
-	// Rotates and shifts
-	0xCB: {0xCB, "ROTATES_SHIFTS", 1, 8, none, rotatesAndShifts},
-
+	0x07: {0x07, "RLCA", "", 1, 4, 4, none, rotateA},
+	0x17: {0x17, "RLA", "", 1, 4, 4, none, rotateA},
+	0x0F: {0x0F, "RRCA", "", 1, 4, 4, none, rotateA},
+	0x1F: {0x1F, "RRA", "", 1, 4, 4, none, rotateA},
 	// jump absolute
-	0xC3: {0xC3, "JP", 1, 12, immediate, jp},
-	0xC2: {0xC2, "JPC", 1, 12, immediate, jpc},
-	0xCA: {0xCA, "JPC", 1, 12, immediate, jpc},
-	0xD2: {0xD2, "JPC", 1, 12, immediate, jpc},
-	0xDA: {0xDA, "JPC", 1, 12, immediate, jpc},
-	0xE9: {0xE9, "JP(HL)", 1, 4, none, jpHL},
+	0xC3: {0xC3, "JP", "a16", 3, 16, 16, immediate, jp},
+	0xC2: {0xC2, "JP", "NZ, a16", 3, 12, 16, immediate, jpc},
+	0xCA: {0xCA, "JP", "Z, a16", 3, 12, 16, immediate, jpc},
+	0xD2: {0xD2, "JP", "NC, a16", 3, 12, 16, immediate, jpc},
+	0xDA: {0xDA, "JP", "C, a16", 3, 12, 16, immediate, jpc},
+	0xE9: {0xE9, "JP", "HL", 1, 4, 4, none, jpHL},
 	// jump relative
-	0x18: {0x18, "JR", 1, 8, immediate, jr},
-	0x20: {0x20, "JRC", 1, 8, immediate, jrc},
-	0x28: {0x28, "JRC", 1, 8, immediate, jrc},
-	0x30: {0x30, "JRC", 1, 8, immediate, jrc},
-	0x38: {0x38, "JRC", 1, 8, immediate, jrc},
+	0x18: {0x18, "JR", "e8", 2, 12, 12, immediate, jr},
+	0x20: {0x20, "JR", "NZ, e8", 2, 8, 12, immediate, jrc},
+	0x28: {0x28, "JR", "Z, e8", 2, 8, 12, immediate, jrc},
+	0x30: {0x30, "JR", "NC, e8", 2, 8, 12, immediate, jrc},
+	0x38: {0x38, "JR", "C, e8", 2, 8, 12, immediate, jrc},
 	// function calls and returns
-	0xCD: {0xCD, "CALL", 1, 12, immediate, call},
-	0xC4: {0xC4, "CALLC", 1, 12, immediate, callC},
-	0xCC: {0xCC, "CALLC", 1, 12, immediate, callC},
-	0xD4: {0xD4, "CALLC", 1, 12, immediate, callC},
-	0xDC: {0xDC, "CALLC", 1, 12, immediate, callC},
-	0xC9: {0xC9, "RET", 1, 8, none, ret},
-	0xC0: {0xC0, "RETC", 1, 8, none, retc},
-	0xC8: {0xC8, "RETC", 1, 8, none, retc},
-	0xD0: {0xD0, "RETC", 1, 8, none, retc},
-	0xD8: {0xD8, "RETC", 1, 8, none, retc},
+	0xCD: {0xCD, "CALL", "a16", 3, 24, 24, immediate, call},
+	0xC4: {0xC4, "CALL", "NZ, a16", 3, 12, 24, immediate, callC},
+	0xCC: {0xCC, "CALL", "Z, a16", 3, 12, 24, immediate, callC},
+	0xD4: {0xD4, "CALL", "NC, a16", 3, 12, 24, immediate, callC},
+	0xDC: {0xDC, "CALL", "C, a16", 3, 12, 24, immediate, callC},
+	0xC9: {0xC9, "RET", "", 1, 16, 16, none, ret},
+	0xC0: {0xC0, "RET", "NZ", 1, 8, 20, none, retc},
+	0xC8: {0xC8, "RET", "Z", 1, 8, 20, none, retc},
+	0xD0: {0xD0, "RET", "NC", 1, 8, 20, none, retc},
+	0xD8: {0xD8, "RET", "C", 1, 8, 20, none, retc},
+	0xD9: {0xD9, "RETI", "", 1, 16, 16, none, reti},
 	// system
-	0xC7: {0xC7, "RST", 1, 32, none, rst},
-	0xCF: {0xCF, "RST", 1, 32, none, rst},
-	0xD7: {0xD7, "RST", 1, 32, none, rst},
-	0xDF: {0xDF, "RST", 1, 32, none, rst},
-	0xE7: {0xE7, "RST", 1, 32, none, rst},
-	0xEF: {0xEF, "RST", 1, 32, none, rst},
-	0xF7: {0xF7, "RST", 1, 32, none, rst},
-	0xFF: {0xFF, "RST", 1, 32, none, rst},
+	0xC7: {0xC7, "RST", "$00", 1, 16, 16, none, rst},
+	0xCF: {0xCF, "RST", "$08", 1, 16, 16, none, rst},
+	0xD7: {0xD7, "RST", "$10", 1, 16, 16, none, rst},
+	0xDF: {0xDF, "RST", "$18", 1, 16, 16, none, rst},
+	0xE7: {0xE7, "RST", "$20", 1, 16, 16, none, rst},
+	0xEF: {0xEF, "RST", "$28", 1, 16, 16, none, rst},
+	0xF7: {0xF7, "RST", "$30", 1, 16, 16, none, rst},
+	0xFF: {0xFF, "RST", "$38", 1, 16, 16, none, rst},
 	// NOP
-	0x00: {0x00, "NOP", 1, 4, none, nop},
+	0x00: {0x00, "NOP", "", 1, 4, 4, none, nop},
+	// HALT & STOP
+	0x76: {0x76, "HALT", "", 1, 4, 4, none, halt},
+	0x10: {0x10, "STOP", "n8", 2, 4, 4, none, stop},
 	// interrupts
-	0xF3: {0xF3, "DI", 1, 4, none, disableInterrupt},
-	0xFB: {0xFB, " EI", 1, 4, none, enableInterrupt},
+	0xF3: {0xF3, "DI", "", 1, 4, 4, none, disableInterrupt},
+	0xFB: {0xFB, "EI", "", 1, 4, 4, none, enableInterrupt},
 	// carry flag
-	0x37: {0x37, "SCF", 1, 4, none, scf},
-	0x3F: {0x3F, "CCF", 1, 4, none, ccf},
+	0x37: {0x37, "SCF", "", 1, 4, 4, none, scf},
+	0x3F: {0x3F, "CCF", "", 1, 4, 4, none, ccf},
+}
+
+// CB前缀指令的低3位选择操作数
+var cbOperandNames = [8]string{"B", "C", "D", "E", "H", "L", "(HL)", "A"}
+
+// CB前缀指令集
+var cbInstructionSet = makeCBInstructionSet()
+
+// makeCBInstructionSet CB前缀指令的编码是规则的：
+// bit6~7选择指令类型，移位指令的bit3~5选择移位方式，BIT/RES/SET的bit3~5是位序号，bit0~2选择操作数
+func makeCBInstructionSet() [256]*Instruction {
+	shifts := [8]struct {
+		name    string
+		handler instructionHandler
+	}{
+		{"RLC", rlc}, {"RRC", rrc}, {"RL", rl}, {"RR", rr},
+		{"SLA", sla}, {"SRA", sra}, {"SWAP", swap}, {"SRL", srl},
+	}
+	var set [256]*Instruction
+	for i := range set {
+		code := byte(i)
+		operand := cbOperandNames[code&7]
+		bitIndex := code >> 3 & 7
+		ins := &Instruction{code: code, length: 2, cycles: 8, branched: 8, mode: none}
+		switch code >> 6 {
+		case 0:
+			ins.name, ins.handler = shifts[bitIndex].name, shifts[bitIndex].handler
+			ins.operands = operand
+		case 1:
+			ins.name, ins.handler = "BIT", bit
+			ins.operands = fmt.Sprintf("%d, %s", bitIndex, operand)
+		case 2:
+			ins.name, ins.handler = "RES", resetBit
+			ins.operands = fmt.Sprintf("%d, %s", bitIndex, operand)
+		case 3:
+			ins.name, ins.handler = "SET", setBit
+			ins.operands = fmt.Sprintf("%d, %s", bitIndex, operand)
+		}
+		// (HL)操作数需要读写内存，BIT只读不写
+		if code&7 == 6 {
+			if ins.name == "BIT" {
+				ins.cycles, ins.branched = 12, 12
+			} else {
+				ins.cycles, ins.branched = 16, 16
+			}
+		}
+		set[i] = ins
+	}
+	return set
 }
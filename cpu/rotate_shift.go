@@ -13,38 +13,8 @@ func rotateA(p *Processor, op *Instruction) {
 	}
 }
 
-// rlc,rl, rrc, rr等opcode都是0xCB, 它们通过后面的立即数来编号
-func rotatesAndShifts(p *Processor, _ *Instruction) {
-	code := p.readOperand8(p.pc, immediate)
-	p.pc += 1
-	switch {
-	case code <= 0x07:
-		rlc(p, code)
-	case code >= 0x08 && code <= 0x0F:
-		rrc(p, code)
-	case code >= 0x10 && code <= 0x17:
-		rl(p, code)
-	case code >= 0x18 && code <= 0x1F:
-		rr(p, code)
-	case code >= 0x20 && code <= 0x27:
-		sla(p, code)
-	case code >= 0x28 && code <= 0x2F:
-		sra(p, code)
-	case code >= 0x30 && code <= 0x37:
-		swap(p, code)
-	case code >= 0x38 && code <= 0x3F:
-		srl(p, code)
-	case code >= 0x40 && code <= 0x47:
-		bit(p, code)
-	case code >= 0x80 && code <= 0x87:
-		resetBit(p, code)
-	case code >= 0xC0 && code <= 0xC7:
-		setBit(p, code)
-	}
-}
-
-func rlc(p *Processor, code byte) {
-	switch code {
+func rlc(p *Processor, op *Instruction) {
+	switch op.code {
 	case 0x00:
 		p.b = p.rotateLeft(p.b)
 	case 0x01:
@@ -65,8 +35,8 @@ func rlc(p *Processor, code byte) {
 	}
 }
 
-func rl(p *Processor, code byte) {
-	switch code {
+func rl(p *Processor, op *Instruction) {
+	switch op.code {
 	case 0x10:
 		p.b = p.rotateLeftCarry(p.b)
 	case 0x11:
@@ -87,8 +57,8 @@ func rl(p *Processor, code byte) {
 	}
 }
 
-func rrc(p *Processor, code byte) {
-	switch code {
+func rrc(p *Processor, op *Instruction) {
+	switch op.code {
 	case 0x08:
 		p.b = p.rotateRight(p.b)
 	case 0x09:
@@ -109,8 +79,8 @@ func rrc(p *Processor, code byte) {
 	}
 }
 
-func rr(p *Processor, code byte) {
-	switch code {
+func rr(p *Processor, op *Instruction) {
+	switch op.code {
 	case 0x18:
 		p.b = p.rotateRightCarry(p.b)
 	case 0x19:
@@ -133,8 +103,8 @@ func rr(p *Processor, code byte) {
 
 // SLA N; N = r8
 // N = N << 1
-func sla(p *Processor, code byte) {
-	switch code {
+func sla(p *Processor, op *Instruction) {
+	switch op.code {
 	case 0x20:
 		p.b = p.shiftLeft(p.b)
 	case 0x21:
@@ -157,8 +127,8 @@ func sla(p *Processor, code byte) {
 
 // SRA N; N = r8
 // N = N >> 1; 不改变最高位
-func sra(p *Processor, code byte) {
-	switch code {
+func sra(p *Processor, op *Instruction) {
+	switch op.code {
 	case 0x28:
 		p.b = p.shiftRight(p.b, false)
 	case 0x29:
@@ -183,8 +153,8 @@ func sra(p *Processor, code byte) {
 
 // SRL N; N=r8
 // N = N >> 1; 最高位变为0
-func srl(p *Processor, code byte) {
-	switch code {
+func srl(p *Processor, op *Instruction) {
+	switch op.code {
 	case 0x38:
 		p.b = p.shiftRight(p.b, true)
 	case 0x39:
@@ -208,8 +178,8 @@ func srl(p *Processor, code byte) {
 }
 
 // SWAP N
-func swap(p *Processor, code byte) {
-	switch code {
+func swap(p *Processor, op *Instruction) {
+	switch op.code {
 	case 0x30:
 		p.b = p.swapHighLow(p.b)
 	case 0x31:
@@ -231,80 +201,61 @@ func swap(p *Processor, code byte) {
 }
 
 // BIT n, N; n = 0~7
-func bit(p *Processor, code byte) {
-	n := p.readOperand8(p.pc, immediate)
-	p.pc += 1
-	switch code {
-	case 0x40:
-		p.testBit(p.b, n)
-	case 0x41:
-		p.testBit(p.c, n)
-	case 0x42:
-		p.testBit(p.d, n)
-	case 0x43:
-		p.testBit(p.e, n)
-	case 0x44:
-		p.testBit(p.h, n)
-	case 0x45:
-		p.testBit(p.l, n)
-	case 0x46:
-		val := p.readMem8(p.reg16(p.h, p.l))
-		p.testBit(val, n)
-	case 0x47:
-		p.testBit(p.a, n)
-	}
+func bit(p *Processor, op *Instruction) {
+	p.testBit(p.cbOperand(op.code), op.code>>3&7)
 }
 
 // SET n, N; n = 0~7
-func setBit(p *Processor, code byte) {
-	n := p.readOperand8(p.pc, immediate)
-	p.pc += 1
-	switch code {
-	case 0xC0:
-		p.b = p.setBit(p.b, n)
-	case 0xC1:
-		p.c = p.setBit(p.c, n)
-	case 0xC2:
-		p.d = p.setBit(p.d, n)
-	case 0xC3:
-		p.e = p.setBit(p.e, n)
-	case 0xC4:
-		p.h = p.setBit(p.h, n)
-	case 0xC5:
-		p.l = p.setBit(p.l, n)
-	case 0xC6:
-		addr := p.reg16(p.h, p.l)
-		p.modifyMemory8(addr, func(val byte) byte {
-			return p.setBit(val, n)
-		})
-	case 0xC7:
-		p.a = p.setBit(p.a, n)
+func setBit(p *Processor, op *Instruction) {
+	p.setCBOperand(op.code, p.setBit(p.cbOperand(op.code), op.code>>3&7))
+}
+
+// RES n, N; n = 0~7
+func resetBit(p *Processor, op *Instruction) {
+	p.setCBOperand(op.code, p.resetBit(p.cbOperand(op.code), op.code>>3&7))
+}
+
+// cbOperand 读取CB指令低3位选择的操作数：B, C, D, E, H, L, (HL), A
+func (p *Processor) cbOperand(code byte) byte {
+	switch code & 7 {
+	case 0:
+		return p.b
+	case 1:
+		return p.c
+	case 2:
+		return p.d
+	case 3:
+		return p.e
+	case 4:
+		return p.h
+	case 5:
+		return p.l
+	case 6:
+		return p.readMem8(p.reg16(p.h, p.l))
+	default:
+		return p.a
 	}
 }
 
-func resetBit(p *Processor, code byte) {
-	n := p.readOperand8(p.pc, immediate)
-	p.pc += 1
-	switch code {
-	case 0x80:
-		p.b = p.resetBit(p.b, n)
-	case 0x81:
-		p.c = p.resetBit(p.c, n)
-	case 0x82:
-		p.d = p.resetBit(p.d, n)
-	case 0x83:
-		p.e = p.resetBit(p.e, n)
-	case 0x84:
-		p.h = p.resetBit(p.h, n)
-	case 0x85:
-		p.l = p.resetBit(p.l, n)
-	case 0x86:
-		addr := p.reg16(p.h, p.l)
-		p.modifyMemory8(addr, func(val byte) byte {
-			return p.resetBit(val, n)
-		})
-	case 0x87:
-		p.a = p.resetBit(p.a, n)
+// setCBOperand 写入CB指令低3位选择的操作数
+func (p *Processor) setCBOperand(code, val byte) {
+	switch code & 7 {
+	case 0:
+		p.b = val
+	case 1:
+		p.c = val
+	case 2:
+		p.d = val
+	case 3:
+		p.e = val
+	case 4:
+		p.h = val
+	case 5:
+		p.l = val
+	case 6:
+		p.writeMem8(p.reg16(p.h, p.l), val)
+	default:
+		p.a = val
 	}
 }
 
@@ -0,0 +1,178 @@
+package cpu
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 单步测试向量的格式与社区的SM83单步测试(SingleStepTests/sm83)相同：
+// 每个文件是一组测试，每个测试给出执行一条指令前后的寄存器和内存，以及每个M-cycle的总线访问。
+// testdata/*.json 是手写的少量向量；完整的测试向量体积较大，不放在仓库中，
+// 下载后把 v1/*.json 放到 testdata/sm83 目录即可运行，目录为空时跳过。
+// 指令在精确模式下执行，逐个M-cycle比较总线活动，访问落在错误的周期也会失败。
+
+// 每个文件最多报告的失败数
+const maxFailures = 10
+
+type sm83State struct {
+	A   byte        `json:"a"`
+	B   byte        `json:"b"`
+	C   byte        `json:"c"`
+	D   byte        `json:"d"`
+	E   byte        `json:"e"`
+	F   byte        `json:"f"`
+	H   byte        `json:"h"`
+	L   byte        `json:"l"`
+	PC  uint16      `json:"pc"`
+	SP  uint16      `json:"sp"`
+	IME *byte       `json:"ime"`
+	IE  *byte       `json:"ie"`
+	RAM [][2]uint32 `json:"ram"`
+}
+
+type sm83Test struct {
+	Name    string          `json:"name"`
+	Initial sm83State       `json:"initial"`
+	Final   sm83State       `json:"final"`
+	Cycles  [][]interface{} `json:"cycles"` // [地址, 数据, "r-m"/"-wm"]，内部周期为null或者没有r/w
+}
+
+func TestSingleStep(t *testing.T) {
+	handwritten, _ := filepath.Glob(filepath.Join("testdata", "*.json"))
+	community, _ := filepath.Glob(filepath.Join("testdata", "sm83", "*.json"))
+	if len(community) == 0 {
+		t.Log("no single-step test vectors in testdata/sm83, only running hand-written vectors")
+	}
+	for _, file := range append(handwritten, community...) {
+		file := file
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".json"), func(t *testing.T) {
+			runSingleStepFile(t, file)
+		})
+	}
+}
+
+func runSingleStepFile(t *testing.T, file string) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests []sm83Test
+	if err := json.Unmarshal(data, &tests); err != nil {
+		t.Fatalf("parse %s: %v", file, err)
+	}
+	failures := 0
+	for _, test := range tests {
+		diffs := runSingleStep(test)
+		if len(diffs) == 0 {
+			continue
+		}
+		t.Errorf("%s:\n\t%s", test.Name, strings.Join(diffs, "\n\t"))
+		if failures++; failures == maxFailures {
+			t.Fatalf("too many failures, skipping the remaining tests in %s", file)
+		}
+	}
+}
+
+// runSingleStep 执行一条指令，返回与期望状态不一致的地方
+func runSingleStep(test sm83Test) (diffs []string) {
+	defer func() {
+		if err := recover(); err != nil {
+			diffs = append(diffs, fmt.Sprintf("panic: %v", err))
+		}
+	}()
+	ram := MakeFlatBus()
+	for _, entry := range test.Initial.RAM {
+		ram.Data[entry[0]] = byte(entry[1])
+	}
+	if test.Initial.IE != nil {
+		ram.Data[0xFFFF] = *test.Initial.IE
+	}
+	b := MakeRecordingBus(ram)
+	p := MakeCPU(b)
+	p.Reset()
+	p.SetCycleAccurate(true)
+	p.loadState(test.Initial)
+
+	cycles := p.Step(nil)
+
+	diffs = p.compareState(test.Final)
+	for _, entry := range test.Final.RAM {
+		if actual := ram.Data[entry[0]]; actual != byte(entry[1]) {
+			diffs = append(diffs, fmt.Sprintf("ram[%04X]: expected %02X, got %02X", entry[0], entry[1], actual))
+		}
+	}
+	if expected := uint64(len(test.Cycles)) * 4; cycles != expected || ram.Cycles != expected {
+		diffs = append(diffs, fmt.Sprintf("cycles: expected %d, got %d (memory ticked %d)", expected, cycles, ram.Cycles))
+	}
+	return append(diffs, compareCycles(test.Cycles, b.Cycles)...)
+}
+
+func (p *Processor) loadState(s sm83State) {
+	p.a, p.b, p.c, p.d, p.e, p.f, p.h, p.l = s.A, s.B, s.C, s.D, s.E, s.F, s.H, s.L
+	p.pc, p.sp = s.PC, s.SP
+	p.interruptEnabled = s.IME != nil && *s.IME != 0
+}
+
+func (p *Processor) compareState(s sm83State) []string {
+	var diffs []string
+	reg8 := func(name string, expected, actual byte) {
+		if expected != actual {
+			diffs = append(diffs, fmt.Sprintf("%s: expected %02X, got %02X", name, expected, actual))
+		}
+	}
+	reg16 := func(name string, expected, actual uint16) {
+		if expected != actual {
+			diffs = append(diffs, fmt.Sprintf("%s: expected %04X, got %04X", name, expected, actual))
+		}
+	}
+	reg8("a", s.A, p.a)
+	reg8("f", s.F, p.f)
+	reg8("b", s.B, p.b)
+	reg8("c", s.C, p.c)
+	reg8("d", s.D, p.d)
+	reg8("e", s.E, p.e)
+	reg8("h", s.H, p.h)
+	reg8("l", s.L, p.l)
+	reg16("pc", s.PC, p.pc)
+	reg16("sp", s.SP, p.sp)
+	if s.IME != nil {
+		// EI延迟一条指令生效，这里把等待生效的EI也算作已经开启
+		ime := p.interruptEnabled || (p.pendingInterruptSwitch >= 0 && p.nextInterruptEnable)
+		if expected := *s.IME != 0; expected != ime {
+			diffs = append(diffs, fmt.Sprintf("ime: expected %v, got %v", expected, ime))
+		}
+	}
+	return diffs
+}
+
+// compareCycles 逐个M-cycle比较总线活动，内部周期只比较是否访问了总线
+func compareCycles(cycles [][]interface{}, actual []Access) []string {
+	expected := make([]Access, len(cycles))
+	for i, c := range cycles {
+		if len(c) < 3 {
+			continue
+		}
+		addr, _ := c[0].(float64)
+		data, _ := c[1].(float64)
+		kind, _ := c[2].(string)
+		if strings.ContainsAny(kind, "rw") {
+			expected[i] = Access{Addr: uint16(addr), Data: byte(data), Read: strings.Contains(kind, "r"), Write: strings.Contains(kind, "w")}
+		}
+	}
+	var diffs []string
+	for i := 0; i < len(expected) || i < len(actual); i++ {
+		switch {
+		case i >= len(actual):
+			diffs = append(diffs, fmt.Sprintf("cycle %d: expected %v, got nothing", i, expected[i]))
+		case i >= len(expected):
+			diffs = append(diffs, fmt.Sprintf("cycle %d: unexpected %v", i, actual[i]))
+		case expected[i] != actual[i]:
+			diffs = append(diffs, fmt.Sprintf("cycle %d: expected %v, got %v", i, expected[i], actual[i]))
+		}
+	}
+	return diffs
+}
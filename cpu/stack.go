@@ -2,6 +2,7 @@ package cpu
 
 // ld SP, HL
 func loadSP(p *Processor, _ *Instruction) {
+	p.tickMCycle()
 	p.sp = p.reg16(p.h, p.l)
 }
 
@@ -11,9 +12,13 @@ func saveSP(p *Processor, op *Instruction) {
 	p.writeMem16(addr, p.sp)
 }
 
+// stackPush16 与硬件相同，先用一个内部周期减少SP，再把高字节写入SP-1，低字节写入SP-2
 func (p *Processor) stackPush16(data uint16) {
-	p.sp = p.sp - 2
-	p.writeMem16(p.sp, data)
+	p.tickMCycle()
+	p.sp--
+	p.writeMem8(p.sp, byte(data>>8))
+	p.sp--
+	p.writeMem8(p.sp, byte(data))
 }
 
 func (p *Processor) stackPop16() uint16 {
@@ -50,16 +55,26 @@ func popReg(p *Processor, op *Instruction) {
 	}
 }
 
-// ADD SP, n8
+// ADD SP, e8
 func addSP(p *Processor, op *Instruction) {
 	delta := p.readOperand8(p.pc, op.mode)
-	if delta&0x80 == 0 {
-		// positive
-		p.sp += uint16(delta)
-	} else {
-		// negative
-		p.sp -= uint16(-int16(int8(delta)))
-	}
-	p.setFlag(zeroFlag, false)
-	p.setFlag(subFlag, false)
+	p.tickMCycle()
+	p.tickMCycle()
+	p.sp = p.offsetSP(delta)
+}
+
+// ld HL, SP + e8
+func loadHLFromSP(p *Processor, op *Instruction) {
+	delta := p.readOperand8(p.pc, op.mode)
+	p.tickMCycle()
+	p.writeHL(p.offsetSP(delta))
+}
+
+// offsetSP SP加上有符号数delta，00hc
+// h和c是SP的低字节与delta按无符号数相加时bit3和bit7的进位
+func (p *Processor) offsetSP(delta byte) uint16 {
+	p.f = 0
+	p.setFlag(halfCarryFlag, p.sp&0xF+uint16(delta&0xF) > 0xF)
+	p.setFlag(carryFlag, p.sp&0xFF+uint16(delta) > 0xFF)
+	return p.sp + uint16(int8(delta))
 }
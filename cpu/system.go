@@ -1,9 +1,14 @@
 package cpu
 
+import "github.com/StellarisJAY/gbgo/interrupt"
+
+// 切换CGB速度时cpu暂停的周期数
+const speedSwitchCycles = 8200
+
 func (p *Processor) restart(vector uint16) {
 	p.stackPush16(p.pc)
-	// jump to 0x8000 + n
-	p.pc = 0x8000 + vector
+	// jump to 0x0000 + n
+	p.jump(vector)
 }
 
 // rst: 重启程序，跳转到restart地址：0x00,0x08...0x30,0x38
@@ -35,9 +40,48 @@ func enableInterrupt(p *Processor, _ *Instruction) {
 	p.nextInterruptEnable = true
 }
 
+// DI 立即关闭中断，同时取消尚未生效的EI
 func disableInterrupt(p *Processor, _ *Instruction) {
-	p.pendingInterruptSwitch = 1
+	p.pendingInterruptSwitch = -1
 	p.nextInterruptEnable = false
+	p.interruptEnabled = false
+}
+
+// HALT 进入低功耗状态，直到IE和IF中有同时置位的中断
+func halt(p *Processor, _ *Instruction) {
+	if _, pending := p.bus.PendingInterrupt(); pending && !p.interruptEnabled {
+		// IME=0且已经有中断等待时，cpu不会进入HALT，并触发HALT bug
+		p.haltBug = true
+		return
+	}
+	p.halted = true
+}
+
+// STOP 进入极低功耗状态，直到有按键按下，同时清零DIV
+// CGB模式下如果KEY1已经准备切换速度，则STOP只用于切换速度，不会进入STOP状态，暂停的周期计入这条指令
+func stop(p *Processor, _ *Instruction) {
+	p.bus.Write(0xFF04, 0)
+	if p.bus.SwitchSpeed() {
+		p.stall = speedSwitchCycles
+		return
+	}
+	p.stopped = true
+}
+
+// sleeping 检查cpu是否处于HALT或STOP状态，并在满足唤醒条件时退出该状态
+func (p *Processor) sleeping() bool {
+	if p.halted {
+		// 唤醒后如果IME=1，中断会在下一条指令之前被处理
+		if _, pending := p.bus.PendingInterrupt(); pending {
+			p.halted = false
+		}
+	}
+	if p.stopped {
+		if p.bus.InterruptRequested(interrupt.JoyPadInterrupt) {
+			p.stopped = false
+		}
+	}
+	return p.halted || p.stopped
 }
 
 // carryFlag取反
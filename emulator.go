@@ -6,44 +6,86 @@ import (
 	"github.com/StellarisJAY/gbgo/bus"
 	"github.com/StellarisJAY/gbgo/cartridge"
 	"github.com/StellarisJAY/gbgo/cpu"
+	"github.com/StellarisJAY/gbgo/joypad"
+	"github.com/StellarisJAY/gbgo/model"
 	"github.com/StellarisJAY/gbgo/ppu"
+	"github.com/StellarisJAY/gbgo/timer"
 	"github.com/veandco/go-sdl2/sdl"
-	"io"
+	"log"
 	"os"
 	"time"
 	"unsafe"
 )
 
 type config struct {
-	file  string
-	scale int
-	fps   int64
-	trace bool
+	file     string
+	patch    string
+	scale    int
+	fps      int64
+	trace    bool
+	palette  string
+	rtcSync  bool
+	saveDir  string
+	bootROM  string
+	model    string
+	accurate bool
 }
 
 type Emulator struct {
 	conf     *config
-	game     cartridge.BasicCartridge
+	game     *cartridge.BasicCartridge
 	window   *sdl.Window
 	renderer *sdl.Renderer
 	texture  *sdl.Texture
+	// 第一个可用的手柄，用于转发卡带的震动
+	controller *sdl.GameController
 
 	cpu           *cpu.Processor
 	ppu           *ppu.PPU
 	bus           *bus.Bus
+	joypad        *joypad.Joypad
+	model         model.Model
+	cgbMode       bool // 型号支持CGB并且卡带支持CGB时以CGB模式运行
 	lastFrameTime int64
 	frameCounter  int64
 
+	savePath     string    // 存档文件路径
+	lastRAMWrite time.Time // 最后一次写入卡带RAM的时间，已经保存后为零值
+
 	traceFunc cpu.InstructionCallback
 }
 
-func parseConfigs() *config {
+// 马达开启后持续震动的最长时间(毫秒)，卡带关闭马达时会提前停止
+const rumbleDuration = 1000
+
+// keyMap 键盘到Game Boy按键的映射
+var keyMap = map[sdl.Scancode]joypad.Button{
+	sdl.SCANCODE_W:         joypad.Up,
+	sdl.SCANCODE_S:         joypad.Down,
+	sdl.SCANCODE_A:         joypad.Left,
+	sdl.SCANCODE_D:         joypad.Right,
+	sdl.SCANCODE_J:         joypad.A,
+	sdl.SCANCODE_K:         joypad.B,
+	sdl.SCANCODE_RETURN:    joypad.Start,
+	sdl.SCANCODE_BACKSPACE: joypad.Select,
+}
+
+// parseConfigs 解析run命令的参数
+func parseConfigs(args []string) *config {
 	conf := &config{}
-	flag.StringVar(&conf.file, "file", "", "game rom file")
-	flag.IntVar(&conf.scale, "scale", 1, "window scale")
-	flag.Int64Var(&conf.fps, "fps", 30, "frame rate")
-	flag.BoolVar(&conf.trace, "trace", false, "trace instructions")
-	flag.Parse()
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	flags.StringVar(&conf.file, "file", "", "game rom file, .zip and .gz archives are supported, use archive.zip#entry to pick a file in zip, - reads from stdin")
+	flags.StringVar(&conf.patch, "patch", "", "ips, ups or bps patch applied at load time, defaults to <rom>.ips|.ups|.bps next to the rom")
+	flags.IntVar(&conf.scale, "scale", 1, "window scale")
+	flags.Int64Var(&conf.fps, "fps", 30, "frame rate")
+	flags.BoolVar(&conf.trace, "trace", false, "trace instructions")
+	flags.StringVar(&conf.saveDir, "save-dir", "", "directory for battery save files, defaults to the rom's directory")
+	flags.BoolVar(&conf.rtcSync, "rtc-sync", false, "sync cartridge real-time clock to host time on load")
+	flags.StringVar(&conf.palette, "palette", ppu.DefaultColorScheme, "color scheme: green, gray, pocket or 4 hex colors like #E0F8D0,#88C070,#346856,#081820")
+	flags.StringVar(&conf.bootROM, "bootrom", "", "boot rom file, DMG (256 bytes) or CGB (2304 bytes)")
+	flags.StringVar(&conf.model, "model", "", "hardware model: DMG0, DMG, MGB, CGB, AGB, SGB or SGB2, defaults to the boot rom's or the cartridge's")
+	flags.BoolVar(&conf.accurate, "accurate", false, "M-cycle accurate timing: devices advance on every memory access, slower")
+	_ = flags.Parse(args)
 	if conf.fps < 20 {
 		conf.fps = 20
 	} else if conf.fps > 60 {
@@ -52,19 +94,6 @@ func parseConfigs() *config {
 	return conf
 }
 
-func readGbFile(fileName string) []byte {
-	file, err := os.OpenFile(fileName, os.O_RDONLY, os.ModePerm)
-	if err != nil {
-		panic(fmt.Errorf("open gb file error %w", err))
-	}
-	defer file.Close()
-	raw, err := io.ReadAll(file)
-	if err != nil {
-		panic(fmt.Errorf("read gb file error %w", err))
-	}
-	return raw
-}
-
 func initSDL(conf *config) (*sdl.Window, *sdl.Renderer, *sdl.Texture) {
 	if err := sdl.Init(sdl.INIT_EVERYTHING); err != nil {
 		panic(fmt.Errorf("init sdl error %w", err))
@@ -87,35 +116,116 @@ func initSDL(conf *config) (*sdl.Window, *sdl.Renderer, *sdl.Texture) {
 	return window, renderer, texture
 }
 
-func MakeEmulator() *Emulator {
-	conf := parseConfigs()
-	raw := readGbFile(conf.file)
-	c := cartridge.MakeBasicCartridge(raw)
+func MakeEmulator(conf *config) *Emulator {
+	c, err := loadCartridge(conf.file, conf.patch)
+	if err != nil {
+		panic(err)
+	}
 	window, renderer, texture := initSDL(conf)
-	b := bus.MakeBus(&c)
+	b := bus.MakeBus(c)
 	gpu := ppu.MakePPU(b.RequestInterrupt)
+	scheme, err := ppu.ParseColorScheme(conf.palette)
+	if err != nil {
+		panic(fmt.Errorf("parse palette error %w", err))
+	}
+	gpu.SetColorScheme(scheme)
 	b.ConnectPPU(gpu)
+	b.ConnectTimer(timer.MakeTimer(b.RequestInterrupt))
+	pad := joypad.MakeJoypad(b.RequestInterrupt)
+	b.ConnectJoypad(pad)
+	var bootROM []byte
+	if conf.bootROM != "" {
+		if bootROM, err = os.ReadFile(conf.bootROM); err != nil {
+			panic(fmt.Errorf("read boot rom error %w", err))
+		}
+		if err := b.LoadBootROM(bootROM); err != nil {
+			panic(err)
+		}
+	}
+	m, err := selectModel(conf.model, bootROM, c)
+	if err != nil {
+		panic(err)
+	}
+	cgbMode := m.IsCGB() && c.IsCGBMode()
+	b.SetCGBMode(cgbMode)
 	processor := cpu.MakeCPU(b)
+	processor.SetCycleAccurate(conf.accurate)
+	b.SetCycleAccurate(conf.accurate)
 	var traceFunc cpu.InstructionCallback
 	if conf.trace {
 		traceFunc = logInstruction
 	}
-	return &Emulator{
-		conf:      conf,
-		game:      c,
-		window:    window,
-		renderer:  renderer,
-		texture:   texture,
-		bus:       b,
-		ppu:       gpu,
-		cpu:       processor,
-		traceFunc: traceFunc,
+	e := &Emulator{
+		conf:       conf,
+		game:       c,
+		window:     window,
+		renderer:   renderer,
+		texture:    texture,
+		controller: openController(),
+		bus:        b,
+		ppu:        gpu,
+		joypad:     pad,
+		model:      m,
+		cgbMode:    cgbMode,
+		cpu:        processor,
+		traceFunc:  traceFunc,
+		savePath:   savePath(conf.file, conf.saveDir, c.Header()),
+	}
+	if err := e.loadSave(); err != nil {
+		panic(err)
+	}
+	// 读取存档中的RTC之后再同步时钟
+	if conf.rtcSync {
+		e.game.SyncClock(time.Now())
+	}
+	e.game.SetRumbleHandler(e.onRumble)
+	return e
+}
+
+// selectModel 选择硬件型号，没有指定时根据启动ROM的大小或者卡带头部的硬件模式决定
+func selectModel(name string, bootROM []byte, c *cartridge.BasicCartridge) (model.Model, error) {
+	switch {
+	case name != "":
+		return model.Parse(name)
+	case len(bootROM) == 0x100:
+		return model.DMG, nil
+	case len(bootROM) > 0:
+		return model.CGB, nil
+	}
+	switch c.Header().HardwareMode() {
+	case cartridge.CGB:
+		return model.CGB, nil
+	case cartridge.SGB:
+		return model.SGB, nil
+	}
+	return model.DMG, nil
+}
+
+// openController 打开第一个SDL game controller，没有手柄时返回nil
+func openController() *sdl.GameController {
+	for i := 0; i < sdl.NumJoysticks(); i++ {
+		if sdl.IsGameController(i) {
+			return sdl.GameControllerOpen(i)
+		}
+	}
+	return nil
+}
+
+// onRumble 卡带马达状态变化时，转发给手柄，没有手柄时输出日志
+func (e *Emulator) onRumble(on bool) {
+	if e.controller == nil {
+		log.Printf("rumble: %v", on)
+		return
+	}
+	if on {
+		_ = e.controller.Rumble(0xFFFF, 0xFFFF, rumbleDuration)
+	} else {
+		_ = e.controller.Rumble(0, 0, 0)
 	}
 }
 
 func (e *Emulator) start() {
-	// 重置cpu各个寄存器
-	e.cpu.Reset()
+	e.reset()
 	interval := 1000 / e.conf.fps
 	ticker := time.NewTicker(time.Duration(interval) * time.Millisecond)
 	e.lastFrameTime = time.Now().UnixMilli()
@@ -127,6 +237,15 @@ func (e *Emulator) start() {
 	}
 }
 
+// reset 上电，有启动ROM时从0x0000开始执行，否则直接设置为启动ROM结束时的状态
+func (e *Emulator) reset() {
+	e.cpu.Reset()
+	if e.conf.bootROM == "" {
+		e.cpu.SkipBoot(postBootRegisters(e.model, e.game.Header(), e.cgbMode))
+		e.bus.SkipBoot(e.model)
+	}
+}
+
 // Update 每一帧更新一次，每次Update进行IO、执行CPU指令、渲染画面
 func (e *Emulator) Update() {
 	frameTime := time.Now().UnixMilli()
@@ -136,6 +255,7 @@ func (e *Emulator) Update() {
 	e.cpu.Tick(frameTime, e.traceFunc)
 	// 渲染画面
 	e.renderFrame()
+	e.autoSave(time.UnixMilli(frameTime))
 	e.frameCounter++
 	if e.frameCounter == e.conf.fps {
 		e.window.SetTitle(fmt.Sprintf("GBGo fps:%2d", 1000/(frameTime-e.lastFrameTime)))
@@ -145,9 +265,7 @@ func (e *Emulator) Update() {
 }
 
 func (e *Emulator) renderFrame() {
-	// ppu渲染tiles和sprites，生成frame数据
-	e.ppu.Render()
-	// frame数据渲染到屏幕
+	// ppu在每个扫描行结束时已经写入frame数据，这里只需要渲染到屏幕
 	frame := ppu.FrameData()
 	_ = e.texture.Update(nil, unsafe.Pointer(&frame[0]), ppu.Width*3)
 	_ = e.renderer.Copy(e.texture, nil, nil)
@@ -159,15 +277,19 @@ func (e *Emulator) handleEvents() {
 		switch event.(type) {
 		case *sdl.KeyboardEvent:
 			ev := event.(*sdl.KeyboardEvent)
-			switch ev.Keysym.Scancode {
-			case sdl.SCANCODE_ESCAPE:
+			if ev.Keysym.Scancode == sdl.SCANCODE_ESCAPE {
 				e.onShutdown()
 				os.Exit(0)
 				return
-			case sdl.SCANCODE_W:
-			case sdl.SCANCODE_S:
-			case sdl.SCANCODE_A:
-			case sdl.SCANCODE_D:
+			}
+			button, ok := keyMap[ev.Keysym.Scancode]
+			if !ok || ev.Repeat != 0 {
+				continue
+			}
+			if ev.Type == sdl.KEYDOWN {
+				e.joypad.Press(button)
+			} else if ev.Type == sdl.KEYUP {
+				e.joypad.Release(button)
 			}
 		case *sdl.QuitEvent:
 			e.onShutdown()
@@ -178,6 +300,12 @@ func (e *Emulator) handleEvents() {
 }
 
 func (e *Emulator) onShutdown() {
+	if err := e.flushSave(); err != nil {
+		log.Println(err)
+	}
+	if e.controller != nil {
+		e.controller.Close()
+	}
 	_ = e.texture.Destroy()
 	_ = e.renderer.Destroy()
 	_ = e.window.Destroy()
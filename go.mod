@@ -2,4 +2,4 @@ module github.com/StellarisJAY/gbgo
 
 go 1.19
 
-require github.com/veandco/go-sdl2 v0.4.35 // indirect
+require github.com/veandco/go-sdl2 v0.4.35
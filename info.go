@@ -0,0 +1,169 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"os"
+
+	"github.com/StellarisJAY/gbgo/cartridge"
+)
+
+// romInfo info命令输出的ROM信息
+type romInfo struct {
+	File             string `json:"file"`
+	Error            string `json:"error,omitempty"`
+	ValidationError  string `json:"validation_error,omitempty"`
+	Title            string `json:"title"`
+	ManufacturerCode string `json:"manufacturer_code,omitempty"`
+	Licensee         string `json:"licensee"`
+	LicenseeCode     string `json:"licensee_code"`
+	CGB              string `json:"cgb"`
+	SGB              bool   `json:"sgb"`
+	HardwareMode     string `json:"hardware_mode"`
+	Type             byte   `json:"type"`
+	Mapper           string `json:"mapper"`
+	Supported        bool   `json:"supported"`
+	ROMSize          uint32 `json:"rom_size"`
+	RAMSize          uint32 `json:"ram_size"`
+	Destination      string `json:"destination"`
+	Version          byte   `json:"version"`
+	HeaderChecksumOK bool   `json:"header_checksum_ok"`
+	GlobalChecksumOK bool   `json:"global_checksum_ok"`
+	Size             int    `json:"size"`
+	CRC32            string `json:"crc32"`
+	SHA1             string `json:"sha1"`
+}
+
+func infoCommand(args []string) int {
+	flags := flag.NewFlagSet("info", flag.ExitOnError)
+	jsonOutput := flags.Bool("json", false, "print one JSON object per rom, one per line")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: gbgo info [--json] rom...")
+		flags.PrintDefaults()
+	}
+	files := parseInterspersed(flags, args)
+	if len(files) == 0 {
+		flags.Usage()
+		return 2
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	status := 0
+	for _, file := range files {
+		info := inspectRom(file)
+		if info.Error != "" || info.ValidationError != "" {
+			status = 1
+		}
+		if *jsonOutput {
+			_ = encoder.Encode(info)
+		} else {
+			printInfo(info)
+		}
+	}
+	return status
+}
+
+// parseInterspersed 解析参数，flag可以出现在文件名之后，例如 gbgo info game.gb --json
+// flag包遇到第一个非flag参数就停止解析；"--"之后的参数都作为文件名
+func parseInterspersed(flags *flag.FlagSet, args []string) []string {
+	var files []string
+	for {
+		_ = flags.Parse(args)
+		rest := flags.Args()
+		if len(rest) < len(args) && args[len(args)-len(rest)-1] == "--" {
+			return append(files, rest...)
+		}
+		if len(rest) == 0 {
+			return files
+		}
+		files = append(files, rest[0])
+		args = rest[1:]
+	}
+}
+
+// inspectRom 读取ROM并解析头部，不会因为ROM不合法而中断，错误记录在Error中
+func inspectRom(file string) romInfo {
+	info := romInfo{File: file}
+	raw, err := readRomFile(file)
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+	crc := crc32.ChecksumIEEE(raw)
+	sum := sha1.Sum(raw)
+	info.Size = len(raw)
+	info.CRC32 = fmt.Sprintf("%08x", crc)
+	info.SHA1 = hex.EncodeToString(sum[:])
+
+	h, err := cartridge.ParseHeader(raw)
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+	headerSum, globalSum, _ := cartridge.Checksums(raw)
+	info.Title = h.Title
+	info.ManufacturerCode = h.ManufacturerCode
+	info.Licensee = h.Licensee
+	info.LicenseeCode = h.LicenseeCode
+	info.CGB = h.CGB.String()
+	info.SGB = h.SGB
+	info.HardwareMode = h.HardwareMode().String()
+	info.Type = h.Type
+	info.Mapper = h.TypeName()
+	info.ROMSize = h.ROMSize
+	info.RAMSize = h.RAMSize
+	info.Destination = h.Destination.String()
+	info.Version = h.Version
+	info.HeaderChecksumOK = headerSum == h.HeaderChecksum
+	info.GlobalChecksumOK = globalSum == h.GlobalChecksum
+
+	// 是否支持只取决于卡带类型；logo、头部校验和、大小等问题单独记录，全局校验和错误不影响运行
+	info.Supported = cartridge.SupportedType(h.Type)
+	if err := cartridge.ValidateHeader(raw); err != nil {
+		info.ValidationError = err.Error()
+	}
+	return info
+}
+
+func printInfo(info romInfo) {
+	fmt.Printf("file:            %s\n", info.File)
+	if info.Error != "" {
+		fmt.Printf("error:           %s\n", info.Error)
+	}
+	if info.Size == 0 {
+		fmt.Println()
+		return
+	}
+	if info.ValidationError != "" {
+		fmt.Printf("invalid:         %s\n", info.ValidationError)
+	}
+	fmt.Printf("title:           %s\n", info.Title)
+	if info.ManufacturerCode != "" {
+		fmt.Printf("manufacturer:    %s\n", info.ManufacturerCode)
+	}
+	fmt.Printf("licensee:        %s (%s)\n", info.Licensee, info.LicenseeCode)
+	fmt.Printf("cgb:             %s\n", info.CGB)
+	fmt.Printf("sgb:             %v\n", info.SGB)
+	fmt.Printf("hardware mode:   %s\n", info.HardwareMode)
+	fmt.Printf("mapper:          %s (0x%02X, supported: %v)\n", info.Mapper, info.Type, info.Supported)
+	fmt.Printf("rom size:        %d KiB\n", info.ROMSize>>10)
+	fmt.Printf("ram size:        %d KiB\n", info.RAMSize>>10)
+	fmt.Printf("destination:     %s\n", info.Destination)
+	fmt.Printf("version:         %d\n", info.Version)
+	fmt.Printf("header checksum: %s\n", checksumStatus(info.HeaderChecksumOK))
+	fmt.Printf("global checksum: %s\n", checksumStatus(info.GlobalChecksumOK))
+	fmt.Printf("size:            %d\n", info.Size)
+	fmt.Printf("crc32:           %s\n", info.CRC32)
+	fmt.Printf("sha1:            %s\n", info.SHA1)
+	fmt.Println()
+}
+
+func checksumStatus(ok bool) string {
+	if ok {
+		return "ok"
+	}
+	return "mismatch"
+}
@@ -15,6 +15,9 @@ const (
 	JoyPadInterrupt
 )
 
+// priorities 中断优先级，从高到低
+var priorities = []Code{VBlankInterrupt, LCDStatInterrupt, TimerInterrupt, SerialInterrupt, JoyPadInterrupt}
+
 func NewRegister() *Register {
 	return &Register{0}
 }
@@ -40,3 +43,34 @@ func (r *Register) Poll() byte {
 	r.data = 0
 	return result
 }
+
+// Read 读取寄存器的原始值
+func (r *Register) Read() byte {
+	return r.data
+}
+
+// Write 写入寄存器的原始值
+func (r *Register) Write(data byte) {
+	r.data = data
+}
+
+// Vector 中断处理程序的入口地址：0x40,0x48,0x50,0x58,0x60
+func (c Code) Vector() uint16 {
+	for i, code := range priorities {
+		if code == c {
+			return 0x40 + uint16(i)*8
+		}
+	}
+	return 0
+}
+
+// Pending 找到IE和IF中同时置位的、优先级最高的中断
+func Pending(enable, flag *Register) (Code, bool) {
+	pending := enable.data & flag.data
+	for _, code := range priorities {
+		if pending&byte(code) != 0 {
+			return code, true
+		}
+	}
+	return 0, false
+}
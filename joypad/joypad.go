@@ -0,0 +1,82 @@
+package joypad
+
+import "github.com/StellarisJAY/gbgo/interrupt"
+
+type Button byte
+
+// 按键编号，低4位是方向键，高4位是功能键，与P1寄存器的低4位对应
+const (
+	Right Button = iota
+	Left
+	Up
+	Down
+	A
+	B
+	Select
+	Start
+)
+
+const (
+	selectDirection byte = 1 << 4 // P1 bit4为0时选择方向键
+	selectAction    byte = 1 << 5 // P1 bit5为0时选择功能键
+)
+
+// Joypad P1/JOYP寄存器，0xFF00
+type Joypad struct {
+	pressed   byte // 第n位为1表示按键n被按下
+	selection byte // P1 bit4~5
+
+	interruptRequester interrupt.Requester
+}
+
+func MakeJoypad(requester interrupt.Requester) *Joypad {
+	return &Joypad{
+		selection:          selectDirection | selectAction,
+		interruptRequester: requester,
+	}
+}
+
+// lines P1低4位的输入线，按下为0
+func (j *Joypad) lines() byte {
+	var lines byte = 0x0F
+	if j.selection&selectDirection == 0 {
+		lines &= ^(j.pressed & 0x0F)
+	}
+	if j.selection&selectAction == 0 {
+		lines &= ^(j.pressed >> 4)
+	}
+	return lines
+}
+
+// update 修改按键状态或选择位，输入线从高变低时发起中断
+func (j *Joypad) update(modify func()) {
+	before := j.lines()
+	modify()
+	if before & ^j.lines() != 0 {
+		j.interruptRequester(interrupt.JoyPadInterrupt)
+	}
+}
+
+func (j *Joypad) Press(button Button) {
+	j.update(func() {
+		j.pressed |= 1 << button
+	})
+}
+
+func (j *Joypad) Release(button Button) {
+	j.update(func() {
+		j.pressed &= ^(1 << button)
+	})
+}
+
+// Read 读取P1寄存器，高2位始终为1
+func (j *Joypad) Read() byte {
+	return 0xC0 | j.selection | j.lines()
+}
+
+// Write 只有bit4~5可写
+func (j *Joypad) Write(data byte) {
+	j.update(func() {
+		j.selection = data & (selectDirection | selectAction)
+	})
+}
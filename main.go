@@ -1,7 +1,40 @@
 package main
 
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const usage = `usage: gbgo <command> [flags]
+
+commands:
+  run   run a game rom, see "gbgo run -h"
+  info  print cartridge information of roms, see "gbgo info -h"
+`
+
 func main() {
-	emulator := MakeEmulator()
-	emulator.game.Info()
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	switch cmd := os.Args[1]; {
+	case cmd == "run":
+		runCommand(os.Args[2:])
+	case cmd == "info":
+		os.Exit(infoCommand(os.Args[2:]))
+	case strings.HasPrefix(cmd, "-") && cmd != "-h" && cmd != "--help":
+		// 兼容没有子命令的旧用法：gbgo -file xxx.gb
+		runCommand(os.Args[1:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func runCommand(args []string) {
+	emulator := MakeEmulator(parseConfigs(args))
+	h := emulator.game.Header()
+	fmt.Printf("title: %s, licensee: %s, type: %s, hardware: %s\n", h.Title, h.Licensee, h.TypeName(), h.HardwareMode())
 	emulator.start()
 }
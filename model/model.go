@@ -0,0 +1,43 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Model Game Boy的硬件型号，没有启动ROM时决定cpu寄存器和IO寄存器的初始值
+type Model byte
+
+const (
+	DMG0 Model = iota // 早期的DMG，启动ROM与之后的DMG不同
+	DMG               // Game Boy
+	MGB               // Game Boy Pocket
+	CGB               // Game Boy Color
+	AGB               // Game Boy Advance，运行CGB游戏时与CGB的区别主要是寄存器初始值
+	SGB               // Super Game Boy，只模拟启动后的寄存器状态，不支持SGB指令
+	SGB2              // Super Game Boy 2
+)
+
+var names = [...]string{"DMG0", "DMG", "MGB", "CGB", "AGB", "SGB", "SGB2"}
+
+func (m Model) String() string {
+	if int(m) < len(names) {
+		return names[m]
+	}
+	return "unknown"
+}
+
+// IsCGB 是否支持CGB功能
+func (m Model) IsCGB() bool {
+	return m == CGB || m == AGB
+}
+
+// Parse 解析型号名称，不区分大小写
+func Parse(name string) (Model, error) {
+	for i, n := range names {
+		if strings.EqualFold(name, n) {
+			return Model(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown model %q, expected one of %s", name, strings.Join(names[:], ", "))
+}
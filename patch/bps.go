@@ -0,0 +1,97 @@
+package patch
+
+var bpsMagic = []byte("BPS1")
+
+// BPS的四种指令，低2位是指令，其余位是长度-1
+const (
+	sourceRead = iota // 从原ROM相同位置复制
+	targetRead        // 从补丁中复制
+	sourceCopy        // 从原ROM的相对位置复制
+	targetCopy        // 从已经输出的数据中复制，可以与输出位置重叠
+)
+
+// applyBPS BPS补丁头部是原ROM大小、结果大小和元数据，之后是复制指令
+func applyBPS(rom, data []byte) ([]byte, error) {
+	r, err := checkFooter(rom, data, bpsMagic)
+	if err != nil {
+		return nil, err
+	}
+	sourceSize, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	targetSize, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if sourceSize != len(rom) {
+		return nil, &SizeError{Expected: sourceSize, Actual: len(rom)}
+	}
+	metadataSize, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.bytes(metadataSize); err != nil {
+		return nil, err
+	}
+	out := make([]byte, targetSize)
+	outPos, sourcePos, targetPos := 0, 0, 0
+	for !r.done() {
+		action, err := r.varint()
+		if err != nil {
+			return nil, err
+		}
+		length := action>>2 + 1
+		if targetSize-outPos < length {
+			return nil, ErrCorrupt
+		}
+		switch action & 3 {
+		case sourceRead:
+			if outPos+length > len(rom) {
+				return nil, ErrCorrupt
+			}
+			copy(out[outPos:], rom[outPos:outPos+length])
+		case targetRead:
+			b, err := r.bytes(length)
+			if err != nil {
+				return nil, err
+			}
+			copy(out[outPos:], b)
+		case sourceCopy:
+			if sourcePos, err = r.relative(sourcePos); err != nil {
+				return nil, err
+			}
+			if sourcePos < 0 || sourcePos+length > len(rom) {
+				return nil, ErrCorrupt
+			}
+			copy(out[outPos:], rom[sourcePos:sourcePos+length])
+			sourcePos += length
+		case targetCopy:
+			if targetPos, err = r.relative(targetPos); err != nil {
+				return nil, err
+			}
+			if targetPos < 0 || targetPos >= outPos {
+				return nil, ErrCorrupt
+			}
+			// 源和目标可能重叠，必须逐字节复制
+			for i := 0; i < length; i++ {
+				out[outPos+i] = out[targetPos+i]
+			}
+			targetPos += length
+		}
+		outPos += length
+	}
+	return out, checkTarget(out, data)
+}
+
+// relative 读取相对偏移，最低位是符号位
+func (r *reader) relative(base int) (int, error) {
+	v, err := r.varint()
+	if err != nil {
+		return 0, err
+	}
+	if v&1 != 0 {
+		return base - v>>1, nil
+	}
+	return base + v>>1, nil
+}
@@ -0,0 +1,51 @@
+package patch
+
+var ipsMagic = []byte("PATCH")
+
+// ipsEOF 结束标记"EOF"，与偏移量0x454F46的记录无法区分，所以IPS不能修改这个地址
+const ipsEOF = 0x454F46
+
+// applyIPS IPS补丁由若干记录组成：3字节偏移、2字节长度、数据
+// 长度为0时是RLE记录：2字节重复次数、1字节数据；EOF之后可能有3字节的截断长度
+func applyIPS(rom, data []byte) ([]byte, error) {
+	out := append([]byte(nil), rom...)
+	r := &reader{data: data, pos: len(ipsMagic), end: len(data)}
+	for {
+		offset, err := r.bigEndian(3)
+		if err != nil {
+			return nil, err
+		}
+		if offset == ipsEOF {
+			break
+		}
+		size, err := r.bigEndian(2)
+		if err != nil {
+			return nil, err
+		}
+		var chunk []byte
+		if size == 0 {
+			count, err := r.bigEndian(2)
+			if err != nil {
+				return nil, err
+			}
+			value, err := r.byte()
+			if err != nil {
+				return nil, err
+			}
+			chunk = make([]byte, count)
+			for i := range chunk {
+				chunk[i] = value
+			}
+		} else if chunk, err = r.bytes(size); err != nil {
+			return nil, err
+		}
+		if end := offset + len(chunk); end > len(out) {
+			out = append(out, make([]byte, end-len(out))...)
+		}
+		copy(out[offset:], chunk)
+	}
+	if truncate, err := r.bigEndian(3); err == nil && truncate < len(out) {
+		out = out[:truncate]
+	}
+	return out, nil
+}
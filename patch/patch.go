@@ -0,0 +1,156 @@
+package patch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Format 补丁格式
+type Format byte
+
+const (
+	IPS Format = iota
+	UPS
+	BPS
+)
+
+var formatNames = [...]string{"IPS", "UPS", "BPS"}
+
+func (f Format) String() string {
+	if int(f) < len(formatNames) {
+		return formatNames[f]
+	}
+	return "unknown"
+}
+
+// Extensions 自动查找补丁文件时使用的扩展名，顺序即优先级
+var Extensions = []string{".ips", ".ups", ".bps"}
+
+var (
+	// ErrUnknownFormat 补丁文件头不是IPS、UPS或BPS
+	ErrUnknownFormat = errors.New("unknown patch format")
+	// ErrCorrupt 补丁数据被截断或者记录越界
+	ErrCorrupt = errors.New("corrupt patch")
+)
+
+// CRCError UPS/BPS补丁中记录的CRC32与实际数据不一致
+type CRCError struct {
+	Target   string // source、target或patch
+	Expected uint32
+	Actual   uint32
+}
+
+func (e *CRCError) Error() string {
+	return fmt.Sprintf("%s crc32 mismatch: expected %08x, got %08x", e.Target, e.Expected, e.Actual)
+}
+
+// SizeError UPS/BPS补丁记录的原ROM大小与实际不一致
+type SizeError struct {
+	Expected int
+	Actual   int
+}
+
+func (e *SizeError) Error() string {
+	return fmt.Sprintf("source size mismatch: patch expects %d bytes, got %d", e.Expected, e.Actual)
+}
+
+// Detect 根据文件头判断补丁格式
+func Detect(data []byte) (Format, error) {
+	switch {
+	case bytes.HasPrefix(data, ipsMagic):
+		return IPS, nil
+	case bytes.HasPrefix(data, upsMagic):
+		return UPS, nil
+	case bytes.HasPrefix(data, bpsMagic):
+		return BPS, nil
+	}
+	return 0, ErrUnknownFormat
+}
+
+// Apply 把补丁应用到rom，返回新的ROM数据，rom本身不会被修改
+// UPS和BPS会校验原ROM、结果和补丁自身的CRC32
+func Apply(rom, data []byte) ([]byte, error) {
+	format, err := Detect(data)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case IPS:
+		return applyIPS(rom, data)
+	case UPS:
+		return applyUPS(rom, data)
+	default:
+		return applyBPS(rom, data)
+	}
+}
+
+// reader 按顺序读取补丁数据，越界时返回ErrCorrupt
+type reader struct {
+	data []byte
+	pos  int
+	end  int // 可以读取的结束位置，UPS/BPS末尾的CRC不属于补丁指令
+}
+
+func (r *reader) done() bool {
+	return r.pos >= r.end
+}
+
+func (r *reader) byte() (byte, error) {
+	if r.pos >= r.end {
+		return 0, ErrCorrupt
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b, nil
+}
+
+func (r *reader) bytes(n int) ([]byte, error) {
+	if n < 0 || r.end-r.pos < n {
+		return nil, ErrCorrupt
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+// bigEndian 读取n字节的大端整数，IPS使用
+func (r *reader) bigEndian(n int) (int, error) {
+	b, err := r.bytes(n)
+	if err != nil {
+		return 0, err
+	}
+	v := 0
+	for _, x := range b {
+		v = v<<8 | int(x)
+	}
+	return v, nil
+}
+
+// maxVarint 变长整数的上限，超过ROM可能的大小时认为数据已经损坏，32位平台上也不会溢出int
+const maxVarint = math.MaxInt32
+
+// varint UPS和BPS使用的变长整数，每个字节的低7位是数据，最高位为1表示结束
+func (r *reader) varint() (int, error) {
+	var value, shift uint64 = 0, 1
+	for {
+		x, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		value += uint64(x&0x7F) * shift
+		if x&0x80 != 0 {
+			break
+		}
+		shift <<= 7
+		value += shift
+		if shift > maxVarint {
+			return 0, ErrCorrupt
+		}
+	}
+	if value > maxVarint {
+		return 0, ErrCorrupt
+	}
+	return int(value), nil
+}
@@ -0,0 +1,247 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"testing"
+)
+
+// patchTest 一个手写补丁的测试用例，err和crc都为空时期望成功
+type patchTest struct {
+	name  string
+	rom   []byte
+	patch []byte
+	want  []byte
+	err   error  // 期望的错误，用errors.Is比较
+	crc   string // 期望*CRCError，值为出错的Target
+}
+
+func runPatchTests(t *testing.T, tests []patchTest) {
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			rom := append([]byte(nil), test.rom...)
+			out, err := Apply(rom, test.patch)
+			if !bytes.Equal(rom, test.rom) {
+				t.Errorf("rom was modified: %q", rom)
+			}
+			switch {
+			case test.crc != "":
+				var crcErr *CRCError
+				if !errors.As(err, &crcErr) || crcErr.Target != test.crc {
+					t.Fatalf("expected %s crc error, got %v", test.crc, err)
+				}
+			case test.err != nil:
+				if !errors.Is(err, test.err) {
+					t.Fatalf("expected %v, got %v", test.err, err)
+				}
+			case err != nil:
+				t.Fatal(err)
+			case !bytes.Equal(out, test.want):
+				t.Fatalf("expected %q, got %q", test.want, out)
+			}
+		})
+	}
+}
+
+// cat 拼接补丁的各个部分
+func cat(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+// varint 编码UPS/BPS的变长整数
+func varint(n int) []byte {
+	var out []byte
+	for {
+		x := byte(n & 0x7F)
+		n >>= 7
+		if n == 0 {
+			return append(out, x|0x80)
+		}
+		out = append(out, x)
+		n--
+	}
+}
+
+// footer 在补丁末尾追加原ROM、结果和补丁自身的CRC32
+func footer(body, source, target []byte) []byte {
+	out := append([]byte(nil), body...)
+	out = binary.LittleEndian.AppendUint32(out, crc32.ChecksumIEEE(source))
+	out = binary.LittleEndian.AppendUint32(out, crc32.ChecksumIEEE(target))
+	return binary.LittleEndian.AppendUint32(out, crc32.ChecksumIEEE(out))
+}
+
+func TestDetect(t *testing.T) {
+	for _, test := range []struct {
+		data   string
+		format Format
+		err    error
+	}{
+		{"PATCH", IPS, nil},
+		{"UPS1", UPS, nil},
+		{"BPS1", BPS, nil},
+		{"PATC", 0, ErrUnknownFormat},
+		{"", 0, ErrUnknownFormat},
+	} {
+		format, err := Detect([]byte(test.data))
+		if format != test.format || err != test.err {
+			t.Errorf("Detect(%q) = %v, %v; expected %v, %v", test.data, format, err, test.format, test.err)
+		}
+	}
+}
+
+func TestVarint(t *testing.T) {
+	for _, n := range []int{0, 1, 0x7F, 0x80, 0x407F, 0x4080, 1 << 20, maxVarint} {
+		r := &reader{data: varint(n), end: len(varint(n))}
+		if v, err := r.varint(); err != nil || v != n {
+			t.Errorf("varint(%d) = %d, %v", n, v, err)
+		}
+	}
+	// 超过maxVarint的数据按损坏处理，而不是溢出
+	data := []byte{0x7F, 0x7F, 0x7F, 0x7F, 0x7F, 0x80}
+	if _, err := (&reader{data: data, end: len(data)}).varint(); err != ErrCorrupt {
+		t.Errorf("expected ErrCorrupt for oversized varint, got %v", err)
+	}
+}
+
+func TestIPS(t *testing.T) {
+	rom := []byte("ABCDEFGH")
+	header, eof := []byte("PATCH"), []byte("EOF")
+	runPatchTests(t, []patchTest{
+		{
+			name:  "record",
+			rom:   rom,
+			patch: cat(header, []byte{0, 0, 2, 0, 2, 'c', 'd'}, eof),
+			want:  []byte("ABcdEFGH"),
+		},
+		{
+			name:  "rle",
+			rom:   rom,
+			patch: cat(header, []byte{0, 0, 1, 0, 0, 0, 3, 'z'}, eof),
+			want:  []byte("AzzzEFGH"),
+		},
+		{
+			name:  "extend",
+			rom:   rom,
+			patch: cat(header, []byte{0, 0, 10, 0, 2, 'x', 'y'}, eof),
+			want:  []byte("ABCDEFGH\x00\x00xy"),
+		},
+		{
+			name:  "multiple records",
+			rom:   rom,
+			patch: cat(header, []byte{0, 0, 0, 0, 1, 'a'}, []byte{0, 0, 6, 0, 0, 0, 2, '-'}, eof),
+			want:  []byte("aBCDEF--"),
+		},
+		{
+			name:  "truncate",
+			rom:   rom,
+			patch: cat(header, []byte{0, 0, 0, 0, 1, 'a'}, eof, []byte{0, 0, 4}),
+			want:  []byte("aBCD"),
+		},
+		{
+			name:  "truncate longer than rom",
+			rom:   rom,
+			patch: cat(header, eof, []byte{0, 0, 0x20}),
+			want:  rom,
+		},
+		{
+			name:  "missing eof",
+			rom:   rom,
+			patch: cat(header, []byte{0, 0, 0, 0, 1, 'a'}),
+			err:   ErrCorrupt,
+		},
+		{
+			name:  "truncated record",
+			rom:   rom,
+			patch: cat(header, []byte{0, 0, 0, 0, 4, 'a'}),
+			err:   ErrCorrupt,
+		},
+	})
+}
+
+func TestUPS(t *testing.T) {
+	source, target := []byte("ABCDEFGH"), []byte("ABcDEFGHxy")
+	// 跳过2字节后异或'C'^'c'，再跳过4字节写入超出原ROM的"xy"
+	body := cat([]byte("UPS1"), varint(len(source)), varint(len(target)),
+		varint(2), []byte{'C' ^ 'c', 0}, varint(4), []byte{'x', 'y', 0})
+	shrink := cat([]byte("UPS1"), varint(len(source)), varint(4), varint(0), []byte{'A' ^ 'a', 0})
+
+	runPatchTests(t, []patchTest{
+		{name: "grow", rom: source, patch: footer(body, source, target), want: target},
+		{name: "shrink", rom: source, patch: footer(shrink, source, []byte("aBCD")), want: []byte("aBCD")},
+		{name: "source crc", rom: []byte("ABCDEFGX"), patch: footer(body, source, target), crc: "source"},
+		{name: "target crc", rom: source, patch: footer(body, source, []byte("ABcDEFGHxz")), crc: "target"},
+		{name: "patch crc", rom: source, patch: cat(footer(body, source, target)[:len(body)+8], []byte{0, 0, 0, 0}), crc: "patch"},
+		{name: "too short", rom: source, patch: []byte("UPS1"), err: ErrCorrupt},
+	})
+
+	// 原ROM的CRC相同但大小不一致时报告SizeError
+	sized := footer(cat([]byte("UPS1"), varint(4), varint(4), varint(0), []byte{0x01, 0}), source, source)
+	var sizeErr *SizeError
+	if _, err := Apply(source, sized); !errors.As(err, &sizeErr) || sizeErr.Expected != 4 || sizeErr.Actual != len(source) {
+		t.Errorf("expected size error, got %v", err)
+	}
+}
+
+// bpsAction 编码BPS指令，低2位是指令，其余位是长度-1
+func bpsAction(kind, length int) []byte {
+	return varint((length-1)<<2 | kind)
+}
+
+// bpsOffset 编码BPS的相对偏移，最低位是符号位
+func bpsOffset(delta int) []byte {
+	if delta < 0 {
+		return varint(-delta<<1 | 1)
+	}
+	return varint(delta << 1)
+}
+
+func TestBPS(t *testing.T) {
+	source := []byte("ABCDEFGH")
+	header := func(target []byte) []byte {
+		return cat([]byte("BPS1"), varint(len(source)), varint(len(target)), varint(0))
+	}
+	// 依次使用四种指令："AB"来自原ROM相同位置，"xy"来自补丁，
+	// "EFG"从原ROM偏移4复制，最后从已输出的位置1复制"Bxy"
+	target := []byte("ABxyEFGBxy")
+	body := cat(header(target),
+		bpsAction(sourceRead, 2),
+		bpsAction(targetRead, 2), []byte("xy"),
+		bpsAction(sourceCopy, 3), bpsOffset(4),
+		bpsAction(targetCopy, 3), bpsOffset(1))
+	// targetCopy的源与输出重叠时逐字节复制，得到重复的图案
+	repeat := []byte("ABABABAB")
+	overlap := cat(header(repeat), bpsAction(sourceRead, 2), bpsAction(targetCopy, 6), bpsOffset(0))
+	// sourceCopy的相对偏移可以向前
+	backward := []byte("FGHDEF")
+	relative := cat(header(backward),
+		bpsAction(sourceCopy, 3), bpsOffset(5),
+		bpsAction(sourceCopy, 3), bpsOffset(-5))
+
+	runPatchTests(t, []patchTest{
+		{name: "all actions", rom: source, patch: footer(body, source, target), want: target},
+		{name: "overlapping target copy", rom: source, patch: footer(overlap, source, repeat), want: repeat},
+		{name: "relative source copy", rom: source, patch: footer(relative, source, backward), want: backward},
+		{name: "source crc", rom: []byte("ABCDEFGX"), patch: footer(body, source, target), crc: "source"},
+		{name: "target crc", rom: source, patch: footer(body, source, []byte("ABxyEFGBxz")), crc: "target"},
+		{name: "patch crc", rom: source, patch: cat(footer(body, source, target)[:len(body)+8], []byte{1, 2, 3, 4}), crc: "patch"},
+		{
+			name:  "copy past target",
+			rom:   source,
+			patch: footer(cat(header(repeat), bpsAction(sourceRead, 9)), source, repeat),
+			err:   ErrCorrupt,
+		},
+		{
+			name:  "target copy from unwritten data",
+			rom:   source,
+			patch: footer(cat(header(repeat), bpsAction(targetCopy, 2), bpsOffset(0)), source, repeat),
+			err:   ErrCorrupt,
+		},
+	})
+}
@@ -0,0 +1,81 @@
+package patch
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+)
+
+var upsMagic = []byte("UPS1")
+
+// 文件末尾依次是原ROM、结果和补丁自身的CRC32，小端
+const crcFooterSize = 12
+
+// applyUPS UPS补丁记录原ROM与结果的异或差异：
+// 每个块是相对上一个块结尾的偏移量，之后是异或数据，以0x00结束
+func applyUPS(rom, data []byte) ([]byte, error) {
+	r, err := checkFooter(rom, data, upsMagic)
+	if err != nil {
+		return nil, err
+	}
+	sourceSize, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	targetSize, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if sourceSize != len(rom) {
+		return nil, &SizeError{Expected: sourceSize, Actual: len(rom)}
+	}
+	out := make([]byte, targetSize)
+	copy(out, rom)
+	pos := 0
+	for !r.done() {
+		skip, err := r.varint()
+		if err != nil {
+			return nil, err
+		}
+		pos += skip
+		for {
+			x, err := r.byte()
+			if err != nil {
+				return nil, err
+			}
+			if x == 0 {
+				pos++
+				break
+			}
+			if pos >= targetSize {
+				return nil, ErrCorrupt
+			}
+			out[pos] ^= x
+			pos++
+		}
+	}
+	return out, checkTarget(out, data)
+}
+
+// checkFooter 校验补丁文件和原ROM的CRC32，返回指向补丁指令的reader
+func checkFooter(rom, data, magic []byte) (*reader, error) {
+	if len(data) < len(magic)+crcFooterSize {
+		return nil, ErrCorrupt
+	}
+	footer := data[len(data)-crcFooterSize:]
+	if expected, actual := binary.LittleEndian.Uint32(footer[8:]), crc32.ChecksumIEEE(data[:len(data)-4]); expected != actual {
+		return nil, &CRCError{Target: "patch", Expected: expected, Actual: actual}
+	}
+	if expected, actual := binary.LittleEndian.Uint32(footer), crc32.ChecksumIEEE(rom); expected != actual {
+		return nil, &CRCError{Target: "source", Expected: expected, Actual: actual}
+	}
+	return &reader{data: data, pos: len(magic), end: len(data) - crcFooterSize}, nil
+}
+
+// checkTarget 校验应用补丁之后的结果
+func checkTarget(out, data []byte) error {
+	footer := data[len(data)-crcFooterSize:]
+	if expected, actual := binary.LittleEndian.Uint32(footer[4:]), crc32.ChecksumIEEE(out); expected != actual {
+		return &CRCError{Target: "target", Expected: expected, Actual: actual}
+	}
+	return nil
+}
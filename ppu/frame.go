@@ -26,11 +26,11 @@ func FrameData() []byte {
 	return frame
 }
 
-func renderStartingPage() {
-	var x, y uint32 = Width/2 - 8, Height/2 - 8
-	for i := uint32(0); i < 8; i++ {
-		for j := uint32(0); j < 8; j++ {
-			setPixel(x+i, y+j, color{255, 255, 255})
+// clearFrame LCD关闭时屏幕显示最浅的颜色
+func (p *PPU) clearFrame() {
+	for y := uint32(0); y < Height; y++ {
+		for x := uint32(0); x < Width; x++ {
+			setPixel(x, y, p.colors[0])
 		}
 	}
 }
@@ -5,7 +5,7 @@ type LCDStatus struct {
 }
 
 const (
-	modeFlag byte = 1
+	modeFlag byte = 3 // bit0~1，当前的PPU模式
 )
 
 const (
@@ -34,3 +34,11 @@ func (s *LCDStatus) set(attribute byte, stat bool) {
 		s.data &= ^attribute
 	}
 }
+
+func (s *LCDStatus) mode() byte {
+	return s.data & modeFlag
+}
+
+func (s *LCDStatus) setMode(mode byte) {
+	s.data = s.data&^modeFlag | mode&modeFlag
+}
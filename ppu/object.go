@@ -0,0 +1,105 @@
+package ppu
+
+import "sort"
+
+const (
+	maxObjsPerLine  = 10
+	identityPalette = 0xE4 // 颜色编号0~3分别映射到0~3
+)
+
+// 对象属性
+const (
+	objPaletteSelect byte = 1 << (iota + 4) // DMG调色板，0: OBP0，1: OBP1
+	objXFlip
+	objYFlip
+	objBGPriority // 为1时背景和窗口颜色1~3覆盖在对象之上
+)
+
+// object OAM中的一个对象
+type object struct {
+	y     byte // 屏幕Y+16
+	x     byte // 屏幕X+8
+	tile  byte
+	attr  byte
+	index int // OAM中的序号
+}
+
+func (p *PPU) objHeight() byte {
+	if p.lcdc.get(objSize) {
+		return 16
+	}
+	return 8
+}
+
+// searchOAM 按OAM顺序选出与当前扫描行相交的最多10个对象，再按DMG的优先级排序：X越小越优先，X相同时OAM序号越小越优先
+func (p *PPU) searchOAM() {
+	p.lineObjs = p.lineObjs[:0]
+	height := int(p.objHeight())
+	line := int(p.scanline)
+	for i := 0; i < 40 && len(p.lineObjs) < maxObjsPerLine; i++ {
+		obj := object{
+			y:     p.oam[i*4],
+			x:     p.oam[i*4+1],
+			tile:  p.oam[i*4+2],
+			attr:  p.oam[i*4+3],
+			index: i,
+		}
+		top := int(obj.y) - 16
+		if line >= top && line < top+height {
+			p.lineObjs = append(p.lineObjs, obj)
+		}
+	}
+	sort.SliceStable(p.lineObjs, func(i, j int) bool {
+		return p.lineObjs[i].x < p.lineObjs[j].x
+	})
+}
+
+// renderObjects 绘制当前扫描行的对象，每个像素由优先级最高的非透明对象决定
+func (p *PPU) renderObjects() {
+	if !p.lcdc.get(objEnable) {
+		return
+	}
+	var drawn [Width]bool
+	height := p.objHeight()
+	y := uint32(p.scanline)
+	for _, obj := range p.lineObjs {
+		row := p.scanline + 16 - obj.y
+		if obj.attr&objYFlip != 0 {
+			row = height - 1 - row
+		}
+		tile := obj.tile
+		if height == 16 {
+			tile &= 0xFE
+		}
+		tileAddr := 0x8000 + uint16(tile)*16
+		for col := byte(0); col < 8; col++ {
+			x := int(obj.x) - 8 + int(col)
+			if x < 0 || x >= Width || drawn[x] {
+				continue
+			}
+			tileCol := col
+			if obj.attr&objXFlip != 0 {
+				tileCol = 7 - col
+			}
+			// 颜色编号0是透明的，会露出优先级更低的对象
+			index := p.tilePixel(tileAddr, tileCol, row)
+			if index == 0 {
+				continue
+			}
+			drawn[x] = true
+			if obj.attr&objBGPriority != 0 && p.bgIndexes[x] != 0 {
+				continue
+			}
+			setPixel(uint32(x), y, p.objColor(index, obj.attr))
+		}
+	}
+}
+
+// objColor 对象颜色编号经过OBP0或OBP1映射后的颜色
+func (p *PPU) objColor(index byte, attr byte) color {
+	palette := p.objPalette[0]
+	if attr&objPaletteSelect != 0 {
+		palette = p.objPalette[1]
+	}
+	return p.colors[paletteShade(palette, index)]
+}
@@ -0,0 +1,51 @@
+package ppu
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ColorScheme 四种灰度对应的RGB颜色，从浅到深
+type ColorScheme [4]color
+
+// 预置的配色方案
+var colorSchemes = map[string]ColorScheme{
+	// 初代DMG的绿色屏幕
+	"green": {{0x9B, 0xBC, 0x0F}, {0x8B, 0xAC, 0x0F}, {0x30, 0x62, 0x30}, {0x0F, 0x38, 0x0F}},
+	"gray":  {{0xFF, 0xFF, 0xFF}, {0xAA, 0xAA, 0xAA}, {0x55, 0x55, 0x55}, {0x00, 0x00, 0x00}},
+	// Game Boy Pocket
+	"pocket": {{0xC4, 0xCF, 0xA1}, {0x8B, 0x95, 0x6D}, {0x4D, 0x53, 0x3C}, {0x1F, 0x1F, 0x1F}},
+}
+
+// DefaultColorScheme 默认使用灰度
+const DefaultColorScheme = "gray"
+
+// ParseColorScheme 解析配色方案，可以是预置方案的名称，或者是逗号分隔的四个十六进制RGB颜色，例如 #E0F8D0,#88C070,#346856,#081820
+func ParseColorScheme(s string) (ColorScheme, error) {
+	if scheme, ok := colorSchemes[strings.ToLower(s)]; ok {
+		return scheme, nil
+	}
+	var scheme ColorScheme
+	parts := strings.Split(s, ",")
+	if len(parts) != len(scheme) {
+		return scheme, fmt.Errorf("invalid color scheme %q: need a preset name or 4 hex colors", s)
+	}
+	for i, part := range parts {
+		hex := strings.TrimPrefix(strings.TrimSpace(part), "#")
+		if len(hex) != 6 {
+			return scheme, fmt.Errorf("invalid color %q in color scheme", part)
+		}
+		rgb, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return scheme, fmt.Errorf("invalid color %q in color scheme: %w", part, err)
+		}
+		scheme[i] = color{byte(rgb >> 16), byte(rgb >> 8), byte(rgb)}
+	}
+	return scheme, nil
+}
+
+// paletteShade 调色板寄存器中颜色编号对应的灰度，每个颜色编号占2位
+func paletteShade(palette, index byte) byte {
+	return palette >> (index * 2) & 3
+}
@@ -6,8 +6,24 @@ import (
 
 type PPU struct {
 	lcdc       LCDControl
-	scanline   byte
-	oam        []byte
+	stat       LCDStatus
+	scanline   byte   // LY，当前扫描行
+	lyc        byte   // LYC，与LY比较
+	dots       uint64 // 当前扫描行已经经过的周期
+	statLine   bool   // STAT中断信号，上升沿时发起中断
+	scy        byte   // 背景滚动Y
+	scx        byte   // 背景滚动X
+	wy         byte   // 窗口左上角Y
+	wx         byte   // 窗口左上角X+7
+	windowLine byte   // 窗口内部行计数器，只有窗口被绘制的扫描行才会加一
+
+	bgIndexes [Width]byte // 当前扫描行背景和窗口像素的颜色编号，用于对象的优先级判断
+
+	oam        [160]byte // 40个对象，每个4字节：Y、X、tile编号、属性
+	bgPalette  byte      // BGP
+	objPalette [2]byte   // OBP0, OBP1
+	colors     ColorScheme
+	lineObjs   []object // OAM搜索选出的当前扫描行的对象，最多10个
 	vRAMBanks  [][]byte // 两个8KiB的VRAM bank
 	vRAMSelect byte     // CGB mode可切换bank
 
@@ -21,29 +37,62 @@ func MakePPU(requester interrupt.Requester) *PPU {
 			make([]byte, 0x2000),
 		},
 		vRAMSelect: 0,
+		// 与启动ROM结束后的状态一致，LCD和背景已经开启
+		lcdc:       LCDControl{data: ppuEnable | bgWindowTileData | bgWindowEnable},
+		stat:       LCDStatus{data: searchingOAMMode},
+		bgPalette:  identityPalette,
+		objPalette: [2]byte{identityPalette, identityPalette},
+		colors:     colorSchemes[DefaultColorScheme],
+		lineObjs:   make([]object, 0, maxObjsPerLine),
 
 		interruptRequester: requester,
 	}
 }
 
-func (p *PPU) Render() {
-	renderStartingPage()
+// SetColorScheme 设置四种灰度显示的颜色
+func (p *PPU) SetColorScheme(scheme ColorScheme) {
+	p.colors = scheme
 }
 
-func (p *PPU) ReadScanline() byte {
-	return p.scanline
+// CopyOAM OAM DMA，一次写入全部160字节
+func (p *PPU) CopyOAM(data []byte) {
+	copy(p.oam[:], data)
 }
 
-func (p *PPU) WriteOAM(data []byte) {
-	p.oam = data
+// DMAWriteOAM 定时的OAM DMA每个M-cycle写入一个字节，不受PPU模式限制
+func (p *PPU) DMAWriteOAM(index int, data byte) {
+	p.oam[index] = data
+}
+
+// DMAReadVRAM OAM DMA从显存读取源数据，不受PPU模式限制
+func (p *PPU) DMAReadVRAM(addr uint16) byte {
+	return p.vRAMBanks[p.vRAMSelect][addr-0x8000]
+}
+
+func (p *PPU) ReadOAM(addr uint16) byte {
+	if !p.oamAccessible() {
+		return 0xFF
+	}
+	return p.oam[addr-0xFE00]
+}
+
+func (p *PPU) WriteOAM(addr uint16, data byte) {
+	if p.oamAccessible() {
+		p.oam[addr-0xFE00] = data
+	}
 }
 
 func (p *PPU) ReadVRAM(addr uint16) byte {
+	if !p.vRAMAccessible() {
+		return 0xFF
+	}
 	return p.vRAMBanks[p.vRAMSelect][addr-0x8000]
 }
 
 func (p *PPU) WriteVRAM(addr uint16, data byte) {
-	p.vRAMBanks[p.vRAMSelect][addr-0x8000] = data
+	if p.vRAMAccessible() {
+		p.vRAMBanks[p.vRAMSelect][addr-0x8000] = data
+	}
 }
 
 func (p *PPU) SwitchVRAMBank(bank byte) {
@@ -0,0 +1,94 @@
+package ppu
+
+// ReadRegister 读取LCD寄存器，0xFF40~0xFF4B
+func (p *PPU) ReadRegister(addr uint16) byte {
+	switch addr {
+	case 0xFF40: // LCDC
+		return p.lcdc.data
+	case 0xFF41: // STAT，bit7始终为1
+		return p.stat.data | 0x80
+	case 0xFF42: // SCY
+		return p.scy
+	case 0xFF43: // SCX
+		return p.scx
+	case 0xFF44: // LY
+		return p.scanline
+	case 0xFF45: // LYC
+		return p.lyc
+	case 0xFF47: // BGP
+		return p.bgPalette
+	case 0xFF48: // OBP0
+		return p.objPalette[0]
+	case 0xFF49: // OBP1
+		return p.objPalette[1]
+	case 0xFF4A: // WY
+		return p.wy
+	case 0xFF4B: // WX
+		return p.wx
+	}
+	return 0xFF
+}
+
+// WriteRegister 写入LCD寄存器，0xFF40~0xFF4B
+func (p *PPU) WriteRegister(addr uint16, data byte) {
+	switch addr {
+	case 0xFF40: // LCDC
+		p.writeLCDC(data)
+	case 0xFF41: // STAT，只有bit3~6的中断源可写，模式和coincidence位只读
+		p.stat.data = p.stat.data&(modeFlag|lycEqualFlag) | data&(hBlankSource|vBlankSource|oamSource|lycEqualSource)
+		p.updateStatInterrupt()
+	case 0xFF42: // SCY
+		p.scy = data
+	case 0xFF43: // SCX
+		p.scx = data
+	case 0xFF44: // LY 只读
+	case 0xFF45: // LYC
+		p.lyc = data
+		if p.lcdc.get(ppuEnable) {
+			p.compareLYC()
+			p.updateStatInterrupt()
+		}
+	case 0xFF47: // BGP
+		p.bgPalette = data
+	case 0xFF48: // OBP0
+		p.objPalette[0] = data
+	case 0xFF49: // OBP1
+		p.objPalette[1] = data
+	case 0xFF4A: // WY
+		p.wy = data
+	case 0xFF4B: // WX
+		p.wx = data
+	}
+}
+
+func (p *PPU) writeLCDC(data byte) {
+	wasEnabled := p.lcdc.get(ppuEnable)
+	p.lcdc.data = data
+	enabled := p.lcdc.get(ppuEnable)
+	switch {
+	case wasEnabled && !enabled:
+		// 关闭LCD后LY清零，PPU停在模式0，屏幕显示空白
+		p.scanline = 0
+		p.dots = 0
+		p.windowLine = 0
+		p.stat.setMode(hBlankMode)
+		p.statLine = false
+		p.clearFrame()
+	case !wasEnabled && enabled:
+		// 重新开启LCD后从第0行的OAM搜索开始
+		p.stat.setMode(searchingOAMMode)
+		p.compareLYC()
+		p.updateStatInterrupt()
+	}
+}
+
+// vRAMAccessible 模式3时PPU正在读取VRAM，cpu无法访问
+func (p *PPU) vRAMAccessible() bool {
+	return p.stat.mode() != transferDataToLCDCMode
+}
+
+// oamAccessible 模式2和模式3时PPU正在读取OAM，cpu无法访问
+func (p *PPU) oamAccessible() bool {
+	mode := p.stat.mode()
+	return mode != searchingOAMMode && mode != transferDataToLCDCMode
+}
@@ -1,5 +1,94 @@
 package ppu
 
-func (p *PPU) renderTile() {
+const (
+	tileMap0 uint16 = 0x9800
+	tileMap1 uint16 = 0x9C00
+)
 
+// renderScanline 像素传输结束时绘制当前扫描行
+func (p *PPU) renderScanline() {
+	p.renderBackground()
+	p.renderWindow()
+	p.renderObjects()
+}
+
+// renderBackground 根据SCX和SCY绘制背景，背景在256x256的tile map中循环
+func (p *PPU) renderBackground() {
+	y := uint32(p.scanline)
+	if !p.lcdc.get(bgWindowEnable) {
+		// DMG关闭背景和窗口时，整行显示颜色0
+		for x := uint32(0); x < Width; x++ {
+			p.bgIndexes[x] = 0
+			setPixel(x, y, p.bgColor(0))
+		}
+		return
+	}
+	tileMap := tileMap0
+	if p.lcdc.get(bgTileMap) {
+		tileMap = tileMap1
+	}
+	bgY := p.scanline + p.scy
+	for x := uint32(0); x < Width; x++ {
+		bgX := byte(x) + p.scx
+		index := p.tileMapPixel(tileMap, bgX, bgY)
+		p.bgIndexes[x] = index
+		setPixel(x, y, p.bgColor(index))
+	}
+}
+
+// renderWindow 窗口从(WX-7, WY)开始绘制，覆盖在背景上，不会滚动
+func (p *PPU) renderWindow() {
+	if !p.lcdc.get(bgWindowEnable) || !p.lcdc.get(windowEnable) {
+		return
+	}
+	if p.scanline < p.wy || p.wx > 166 {
+		return
+	}
+	tileMap := tileMap0
+	if p.lcdc.get(windowTileMap) {
+		tileMap = tileMap1
+	}
+	y := uint32(p.scanline)
+	for x := int(p.wx) - 7; x < Width; x++ {
+		if x < 0 {
+			continue
+		}
+		windowX := byte(x - (int(p.wx) - 7))
+		index := p.tileMapPixel(tileMap, windowX, p.windowLine)
+		p.bgIndexes[x] = index
+		setPixel(uint32(x), y, p.bgColor(index))
+	}
+	p.windowLine++
+}
+
+// tileMapPixel 获取tile map中(x,y)像素的颜色编号
+func (p *PPU) tileMapPixel(tileMap uint16, x, y byte) byte {
+	tileIndex := p.readVRAMBank0(tileMap + uint16(y/8)*32 + uint16(x/8))
+	return p.tilePixel(p.tileDataAddr(tileIndex), x%8, y%8)
+}
+
+// tileDataAddr 背景和窗口的tile数据地址
+// bgWindowTileData为1时使用0x8000无符号寻址，为0时使用0x9000有符号寻址
+func (p *PPU) tileDataAddr(tileIndex byte) uint16 {
+	if p.lcdc.get(bgWindowTileData) {
+		return 0x8000 + uint16(tileIndex)*16
+	}
+	return uint16(0x9000 + int32(int8(tileIndex))*16)
+}
+
+// tilePixel 读取tile中第row行第col列的颜色编号，每行两个字节，分别是颜色编号的低位和高位
+func (p *PPU) tilePixel(tileAddr uint16, col, row byte) byte {
+	low := p.readVRAMBank0(tileAddr + uint16(row)*2)
+	high := p.readVRAMBank0(tileAddr + uint16(row)*2 + 1)
+	bit := 7 - col
+	return (high>>bit&1)<<1 | low>>bit&1
+}
+
+func (p *PPU) readVRAMBank0(addr uint16) byte {
+	return p.vRAMBanks[0][addr-0x8000]
+}
+
+// bgColor 背景和窗口颜色编号经过BGP映射后的颜色
+func (p *PPU) bgColor(index byte) color {
+	return p.colors[paletteShade(p.bgPalette, index)]
 }
@@ -0,0 +1,93 @@
+package ppu
+
+import "github.com/StellarisJAY/gbgo/interrupt"
+
+const (
+	oamScanDots       uint64 = 80  // 模式2，OAM搜索
+	transferDots      uint64 = 172 // 模式3，传输像素数据
+	scanlineDots      uint64 = 456 // 每个扫描行的周期数
+	vBlankStartLine   byte   = 144
+	scanlinesPerFrame byte   = 154
+)
+
+// Tick 推进cycles个周期，依次经过OAM搜索、像素传输、HBlank，144~153行为VBlank
+func (p *PPU) Tick(cycles uint64) {
+	// LCD关闭时PPU不工作
+	if !p.lcdc.get(ppuEnable) {
+		return
+	}
+	for cycles > 0 {
+		// 当前模式剩余的周期
+		step := p.modeEnd() - p.dots
+		if step > cycles {
+			step = cycles
+		}
+		p.dots += step
+		cycles -= step
+		if p.dots == p.modeEnd() {
+			p.nextMode()
+		}
+	}
+}
+
+// modeEnd 当前模式在本扫描行内结束的周期
+func (p *PPU) modeEnd() uint64 {
+	switch p.stat.mode() {
+	case searchingOAMMode:
+		return oamScanDots
+	case transferDataToLCDCMode:
+		return oamScanDots + transferDots
+	default:
+		return scanlineDots
+	}
+}
+
+func (p *PPU) nextMode() {
+	switch p.stat.mode() {
+	case searchingOAMMode:
+		p.searchOAM()
+		p.stat.setMode(transferDataToLCDCMode)
+	case transferDataToLCDCMode:
+		p.renderScanline()
+		p.stat.setMode(hBlankMode)
+	case hBlankMode:
+		p.nextScanline()
+		if p.scanline == vBlankStartLine {
+			p.stat.setMode(vBlankMode)
+			p.interruptRequester(interrupt.VBlankInterrupt)
+		} else {
+			p.stat.setMode(searchingOAMMode)
+		}
+	case vBlankMode:
+		p.nextScanline()
+		if p.scanline == 0 {
+			p.windowLine = 0
+			p.stat.setMode(searchingOAMMode)
+		}
+	}
+	p.updateStatInterrupt()
+}
+
+func (p *PPU) nextScanline() {
+	p.dots = 0
+	p.scanline = (p.scanline + 1) % scanlinesPerFrame
+	p.compareLYC()
+}
+
+// compareLYC 比较LY和LYC，设置STAT的coincidence位
+func (p *PPU) compareLYC() {
+	p.stat.set(lycEqualFlag, p.scanline == p.lyc)
+}
+
+// updateStatInterrupt 所有开启的STAT中断源共用一条中断信号，只有信号从低变高时才发起中断
+func (p *PPU) updateStatInterrupt() {
+	mode := p.stat.mode()
+	line := p.stat.get(hBlankSource) && mode == hBlankMode ||
+		p.stat.get(vBlankSource) && mode == vBlankMode ||
+		p.stat.get(oamSource) && mode == searchingOAMMode ||
+		p.stat.get(lycEqualSource) && p.stat.get(lycEqualFlag)
+	if line && !p.statLine {
+		p.interruptRequester(interrupt.LCDStatInterrupt)
+	}
+	p.statLine = line
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/StellarisJAY/gbgo/cartridge"
+	"github.com/StellarisJAY/gbgo/patch"
+)
+
+// stdinFile 从标准输入读取ROM时使用的文件名
+const stdinFile = "-"
+
+// zip中指定文件的分隔符，例如 roms.zip#Tetris.gb
+const entrySeparator = "#"
+
+var (
+	zipMagic  = []byte("PK\x03\x04")
+	gzipMagic = []byte{0x1F, 0x8B}
+)
+
+// readRomFile 读取ROM数据，支持普通文件、.zip、.gz以及标准输入"-"
+// 压缩格式根据文件头判断；zip默认取第一个.gb/.gbc文件，也可以用 archive.zip#entry 指定
+func readRomFile(fileName string) ([]byte, error) {
+	file, entry := splitEntry(fileName)
+	var raw []byte
+	var err error
+	if file == stdinFile {
+		raw, err = io.ReadAll(os.Stdin)
+	} else {
+		raw, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read gb file error %w", err)
+	}
+	switch {
+	case bytes.HasPrefix(raw, zipMagic):
+		return readZipEntry(raw, entry)
+	case entry != "":
+		return nil, fmt.Errorf("read gb file error %s is not a zip archive", file)
+	case bytes.HasPrefix(raw, gzipMagic):
+		return readGzip(raw)
+	}
+	return raw, nil
+}
+
+// splitEntry 拆分 archive.zip#entry 形式的文件名，文件本身存在时不拆分
+func splitEntry(fileName string) (string, string) {
+	i := strings.LastIndex(fileName, entrySeparator)
+	if i < 0 {
+		return fileName, ""
+	}
+	if _, err := os.Stat(fileName); err == nil {
+		return fileName, ""
+	}
+	return fileName[:i], fileName[i+1:]
+}
+
+func readZipEntry(raw []byte, entry string) ([]byte, error) {
+	r, err := zip.NewReader(bytes.NewReader(raw), int64(len(raw)))
+	if err != nil {
+		return nil, fmt.Errorf("open zip error %w", err)
+	}
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if entry != "" && f.Name != entry {
+			continue
+		}
+		if entry == "" && !isRomName(f.Name) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("open zip entry %s error %w", f.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read zip entry %s error %w", f.Name, err)
+		}
+		return data, nil
+	}
+	if entry != "" {
+		return nil, fmt.Errorf("zip entry %s not found", entry)
+	}
+	return nil, errors.New("no .gb or .gbc file in zip")
+}
+
+func readGzip(raw []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("open gzip error %w", err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read gzip error %w", err)
+	}
+	return data, nil
+}
+
+func isRomName(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	return ext == ".gb" || ext == ".gbc"
+}
+
+// romName ROM的名称，去掉目录、压缩格式和ROM的扩展名，用于命名存档文件
+// 从标准输入读取时没有文件名，使用卡带标题
+func romName(fileName string, h cartridge.Header) string {
+	file, entry := splitEntry(fileName)
+	if entry != "" {
+		file = entry
+	}
+	if file == stdinFile {
+		if title := strings.TrimSpace(h.Title); title != "" {
+			return strings.Map(func(r rune) rune {
+				if strings.ContainsRune(`/\:*?"<>|`, r) {
+					return '_'
+				}
+				return r
+			}, title)
+		}
+		return "stdin"
+	}
+	name := path.Base(filepath.ToSlash(file))
+	lower := strings.ToLower(name)
+	for _, ext := range []string{".gz", ".zip", ".gbc", ".gb"} {
+		if strings.HasSuffix(lower, ext) {
+			name, lower = name[:len(name)-len(ext)], lower[:len(lower)-len(ext)]
+		}
+	}
+	return name
+}
+
+// findPatch 查找ROM旁边的补丁文件，例如 Tetris.gb.ips 或 Tetris.ips，没有时返回空字符串
+func findPatch(fileName string) string {
+	file, _ := splitEntry(fileName)
+	if file == stdinFile {
+		return ""
+	}
+	name := filepath.Join(filepath.Dir(file), romName(fileName, cartridge.Header{}))
+	for _, ext := range patch.Extensions {
+		for _, candidate := range []string{file + ext, name + ext} {
+			if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+				return candidate
+			}
+		}
+	}
+	return ""
+}
+
+// applyPatch 把补丁文件应用到ROM数据
+func applyPatch(raw []byte, patchFile string) ([]byte, error) {
+	data, err := os.ReadFile(patchFile)
+	if err != nil {
+		return nil, fmt.Errorf("read patch file error %w", err)
+	}
+	patched, err := patch.Apply(raw, data)
+	if err != nil {
+		return nil, fmt.Errorf("apply patch %s error %w", patchFile, err)
+	}
+	return patched, nil
+}
+
+// loadCartridge 读取并校验ROM文件，全局校验和错误只输出警告
+// patchFile为空时自动查找ROM旁边的补丁文件，补丁在解析头部之前应用
+func loadCartridge(fileName, patchFile string) (*cartridge.BasicCartridge, error) {
+	raw, err := readRomFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if patchFile == "" {
+		patchFile = findPatch(fileName)
+	}
+	if patchFile != "" {
+		if raw, err = applyPatch(raw, patchFile); err != nil {
+			return nil, err
+		}
+		log.Printf("applied patch %s", patchFile)
+	}
+	c, err := cartridge.MakeBasicCartridge(raw)
+	if err != nil {
+		return nil, fmt.Errorf("load gb file error %w", err)
+	}
+	if !c.GlobalChecksumOK() {
+		log.Printf("warning: global checksum mismatch: header says 0x%04X", c.Header().GlobalChecksum)
+	}
+	return c, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/StellarisJAY/gbgo/cartridge"
+)
+
+// 存档在最后一次写入RAM之后等待一段时间再写入文件，避免游戏连续写入时频繁保存
+const saveDebounce = time.Second
+
+// savePath 存档文件路径，与ROM同名，扩展名为.sav；没有指定存档目录时与ROM在同一目录
+// 从标准输入读取ROM时，没有指定存档目录则保存在当前目录
+func savePath(romFile, saveDir string, h cartridge.Header) string {
+	if saveDir == "" {
+		saveDir = "."
+		if file, _ := splitEntry(romFile); file != stdinFile {
+			saveDir = filepath.Dir(file)
+		}
+	}
+	return filepath.Join(saveDir, romName(romFile, h)+".sav")
+}
+
+// loadSave 读取存档文件恢复卡带RAM，存档不存在时不做任何事
+func (e *Emulator) loadSave() error {
+	if !e.game.HasBattery() {
+		return nil
+	}
+	data, err := os.ReadFile(e.savePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read save file error %w", err)
+	}
+	e.game.LoadRAM(data)
+	return nil
+}
+
+// flushSave 把卡带RAM写入存档文件，先写入临时文件再重命名，防止写入中断损坏存档
+func (e *Emulator) flushSave() error {
+	if !e.game.HasBattery() {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(e.savePath), 0755); err != nil {
+		return fmt.Errorf("create save dir error %w", err)
+	}
+	tmp := e.savePath + ".tmp"
+	if err := os.WriteFile(tmp, e.game.SaveRAM(), 0644); err != nil {
+		return fmt.Errorf("write save file error %w", err)
+	}
+	if err := os.Rename(tmp, e.savePath); err != nil {
+		return fmt.Errorf("write save file error %w", err)
+	}
+	return nil
+}
+
+// autoSave 每一帧检查RAM是否被写入，写入停止saveDebounce之后保存存档
+func (e *Emulator) autoSave(now time.Time) {
+	if e.game.RAMModified() {
+		e.lastRAMWrite = now
+		return
+	}
+	if e.lastRAMWrite.IsZero() || now.Sub(e.lastRAMWrite) < saveDebounce {
+		return
+	}
+	e.lastRAMWrite = time.Time{}
+	if err := e.flushSave(); err != nil {
+		log.Println(err)
+	}
+}
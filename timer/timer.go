@@ -0,0 +1,116 @@
+package timer
+
+import "github.com/StellarisJAY/gbgo/interrupt"
+
+// Timer 定时器，包含DIV、TIMA、TMA、TAC四个寄存器
+// DIV是内部16位计数器的高8位，TIMA在计数器被选中的位出现下降沿时加一
+type Timer struct {
+	counter uint16 // 内部16位计数器，每个cpu周期加一
+	tima    byte   // 0xFF05 计时器
+	tma     byte   // 0xFF06 TIMA溢出后重新加载的值
+	tac     byte   // 0xFF07 bit2: 是否开启TIMA, bit0~1: TIMA频率
+
+	overflow bool // TIMA溢出，下一个M-cycle重新加载TMA并发起中断
+	reloaded bool // 当前M-cycle刚刚完成TMA的重新加载
+
+	interruptRequester interrupt.Requester
+}
+
+const (
+	timerEnable byte = 1 << 2
+	clockSelect byte = 3
+)
+
+// clockBits TAC频率选择对应的计数器位：4096Hz, 262144Hz, 65536Hz, 16384Hz
+var clockBits = [4]uint16{1 << 9, 1 << 3, 1 << 5, 1 << 7}
+
+func MakeTimer(requester interrupt.Requester) *Timer {
+	return &Timer{
+		interruptRequester: requester,
+	}
+}
+
+// Tick 推进cycles个cpu周期，以M-cycle(4周期)为单位
+func (t *Timer) Tick(cycles uint64) {
+	for i := uint64(0); i < cycles; i += 4 {
+		t.step()
+	}
+}
+
+func (t *Timer) step() {
+	t.reloaded = false
+	if t.overflow {
+		// TIMA溢出后保持0x00一个M-cycle，然后加载TMA并请求中断
+		t.overflow = false
+		t.reloaded = true
+		t.tima = t.tma
+		t.interruptRequester(interrupt.TimerInterrupt)
+	}
+	t.setCounter(t.counter + 4)
+}
+
+// SetCounter 直接设置内部计数器，不会触发TIMA，用于设置启动ROM结束时的状态
+func (t *Timer) SetCounter(value uint16) {
+	t.counter = value
+}
+
+// setCounter 修改内部计数器，被选中的位出现下降沿时TIMA加一
+func (t *Timer) setCounter(value uint16) {
+	before := t.timerBit()
+	t.counter = value
+	if before && !t.timerBit() {
+		t.increment()
+	}
+}
+
+// timerBit TAC开启时，计数器中被选中的位
+func (t *Timer) timerBit() bool {
+	return t.tac&timerEnable != 0 && t.counter&clockBits[t.tac&clockSelect] != 0
+}
+
+func (t *Timer) increment() {
+	t.tima++
+	if t.tima == 0 {
+		t.overflow = true
+	}
+}
+
+func (t *Timer) Read(addr uint16) byte {
+	switch addr {
+	case 0xFF04: // DIV
+		return byte(t.counter >> 8)
+	case 0xFF05: // TIMA
+		return t.tima
+	case 0xFF06: // TMA
+		return t.tma
+	case 0xFF07: // TAC，高5位始终为1
+		return t.tac | 0xF8
+	}
+	return 0xFF
+}
+
+func (t *Timer) Write(addr uint16, data byte) {
+	switch addr {
+	case 0xFF04: // 写DIV会清零整个内部计数器
+		t.setCounter(0)
+	case 0xFF05:
+		// 重新加载TMA的M-cycle中写入TIMA会被忽略
+		if !t.reloaded {
+			t.tima = data
+			t.overflow = false
+		}
+	case 0xFF06:
+		t.tma = data
+		// 重新加载TMA的M-cycle中写入TMA，新的值也会写入TIMA
+		if t.reloaded {
+			t.tima = data
+		}
+	case 0xFF07:
+		// 修改TAC也可能让选中的位出现下降沿
+		before := t.timerBit()
+		t.tac = data & 7
+		if before && !t.timerBit() {
+			t.increment()
+		}
+	}
+}
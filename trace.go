@@ -6,5 +6,9 @@ import (
 )
 
 func logInstruction(ctx cpu.ProcessorContext, ins *cpu.Instruction) {
-	fmt.Printf("%04X  %02X\t%6s\t%04X %04X %04X %04X %d\n", ctx.PC, ins.Code(), ins.Name(), ctx.AF, ctx.BC, ctx.DE, ctx.HL, ctx.Cycles)
+	code := fmt.Sprintf("%02X", ins.Code())
+	if ins.Prefixed() {
+		code = "CB " + code
+	}
+	fmt.Printf("%04X  %-5s\t%-16s\t%04X %04X %04X %04X %d\n", ctx.PC, code, ins, ctx.AF, ctx.BC, ctx.DE, ctx.HL, ctx.Cycles)
 }